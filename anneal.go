@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// AnnealOptions configures simulated annealing. Zero values are replaced with sensible defaults.
+type AnnealOptions struct {
+	// Iterations is the number of neighbouring expressions to try, defaulting to 10,000.
+	Iterations int
+
+	// Temperature is the starting temperature, defaulting to 1. Higher temperatures make it more likely that a worse
+	// expression is accepted early on, which helps escape local minima.
+	Temperature float64
+
+	// Cooling is the factor the temperature is multiplied by after every iteration, defaulting to 0.999.
+	Cooling float64
+
+	// Step is how much numbers are nudged by when tweaking them, defaulting to 1.
+	Step float64
+
+	// Rand is the source of randomness, defaulting to the global source.
+	Rand *rand.Rand
+
+	// GenerateOptions controls which binary operators are swapped in when tweaking operators.
+	GenerateOptions
+}
+
+// withDefaults returns the options with any zero values replaced by their defaults.
+func (opts AnnealOptions) withDefaults() AnnealOptions {
+	if opts.Iterations == 0 {
+		opts.Iterations = 10_000
+	}
+
+	if opts.Temperature == 0 {
+		opts.Temperature = 1
+	}
+
+	if opts.Cooling == 0 {
+		opts.Cooling = 0.999
+	}
+
+	if opts.Step == 0 {
+		opts.Step = 1
+	}
+
+	if opts.Rand == nil {
+		opts.Rand = globalRand
+	}
+
+	return opts
+}
+
+// Anneal searches for an expression close to the target using simulated annealing, starting from the given
+// expression. At each step a random neighbouring expression is tried, which is always accepted if it's closer to the
+// target and otherwise accepted with a probability that shrinks as the temperature cools. It returns the closest
+// expression seen and its distance from the target, which is +Inf if the start can't be evaluated.
+func Anneal(start *Stack, target float64, opts AnnealOptions) (*Stack, float64) {
+	opts = opts.withDefaults()
+
+	current := start.Clone()
+
+	val, err := Evaluate(current)
+	if err != nil || !finite(val) {
+		return current, math.Inf(1)
+	}
+
+	diff := math.Abs(target - val)
+	best, bestDiff := current, diff
+	temperature := opts.Temperature
+
+	for i := 0; i < opts.Iterations; i++ {
+		candidate := neighbour(opts.Rand, current, opts.Step, opts.GenerateOptions)
+
+		candidateVal, err := Evaluate(candidate)
+		if err == nil && finite(candidateVal) {
+			candidateDiff := math.Abs(target - candidateVal)
+
+			if candidateDiff < diff || opts.Rand.Float64() < math.Exp((diff-candidateDiff)/temperature) {
+				current, diff = candidate, candidateDiff
+			}
+
+			if diff < bestDiff {
+				best, bestDiff = current, diff
+			}
+		}
+
+		temperature *= opts.Cooling
+	}
+
+	return best, bestDiff
+}
+
+// neighbour returns a copy of the expression with a single random change: nudging a number up or down by step,
+// swapping a binary operator for another one that opts allows, or adding or removing a square root. Each of these
+// keeps a valid expression valid.
+func neighbour(r *rand.Rand, s *Stack, step float64, opts GenerateOptions) *Stack {
+	for {
+		switch r.Intn(4) {
+		case 0:
+			if i, ok := randomIndex(r, s, func(atom Atom) bool { _, ok := atom.(Number); return ok }); ok {
+				delta := step
+				if r.Intn(2) == 0 {
+					delta = -delta
+				}
+
+				mutated, _ := s.MutateNumber(i, delta)
+				return mutated
+			}
+		case 1:
+			if i, ok := randomIndex(r, s, func(atom Atom) bool { return atom.IsOperator() && valence(atom) == 2 }); ok {
+				if mutated, err := s.SwapOperator(i, opts.randomBinaryOperator(r)); err == nil {
+					return mutated
+				}
+			}
+		case 2:
+			// A unary operator can follow any atom, since every atom ends a subexpression.
+			i := r.Intn(s.Len())
+			items := append(s.Atoms()[:i+1], append([]Atom{SQRT}, s.items[i+1:]...)...)
+			return NewStack(items...)
+		case 3:
+			if i, ok := randomIndex(r, s, func(atom Atom) bool { return atom == SQRT }); ok {
+				items := append(s.Atoms()[:i], s.items[i+1:]...)
+				return NewStack(items...)
+			}
+		}
+	}
+}
+
+// randomIndex returns the index of a random atom in the stack matching the predicate, or false if there are none.
+func randomIndex(r *rand.Rand, s *Stack, matches func(Atom) bool) (int, bool) {
+	indices := []int{}
+
+	for i, atom := range s.items {
+		if matches(atom) {
+			indices = append(indices, i)
+		}
+	}
+
+	if len(indices) == 0 {
+		return 0, false
+	}
+
+	return indices[r.Intn(len(indices))], true
+}
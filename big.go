@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrNotRational is returned by EvaluateRat when part of an expression can't be represented exactly as a fraction.
+var ErrNotRational = errors.New("result is not rational")
+
+// maxRatExponent is the largest exponent EvaluateRat will raise a fraction to, to stop the numerator and denominator
+// growing without bound.
+const maxRatExponent = 1024
+
+// maxFactorial is the largest number EvaluateRat and EvaluateBig will take the factorial of, for the same reason.
+const maxFactorial = 1024
+
+// EvaluateRat evaluates a stack of atoms in postfix notation using exact rational arithmetic. Square roots and powers
+// are only supported when the result is rational, such as "4 √" or "2 10 ^", and constants are never supported;
+// otherwise ErrNotRational is returned.
+func EvaluateRat(s *Stack) (*big.Rat, error) {
+	nums := []*big.Rat{}
+
+	for _, atom := range s.items {
+		switch atom := atom.(type) {
+		case Number:
+			// Numbers are read from their shortest decimal form, so 0.1 is exactly 1/10 rather than the nearest float.
+			num, ok := new(big.Rat).SetString(fmt.Sprint(float64(atom)))
+			if !ok {
+				return nil, fmt.Errorf("%w: %v", ErrNotRational, atom)
+			}
+
+			nums = append(nums, num)
+		case Constant:
+			return nil, fmt.Errorf("%w: constant %s", ErrNotRational, atom)
+		case Variable:
+			return nil, fmt.Errorf("%w %s", ErrUnboundVariable, atom)
+		case Operator:
+			if len(nums) < valence(atom) {
+				return nil, fmt.Errorf("%w at operator %s", ErrStackUnderflow, atom)
+			}
+
+			if valence(atom) == 1 {
+				x := nums[len(nums)-1]
+
+				result, err := ratUnary(atom, x)
+				if err != nil {
+					return nil, err
+				}
+
+				nums[len(nums)-1] = result
+				continue
+			}
+
+			x, y := nums[len(nums)-1], nums[len(nums)-2]
+			nums = nums[:len(nums)-2]
+
+			result, err := ratBinary(atom, y, x)
+			if err != nil {
+				return nil, err
+			}
+
+			nums = append(nums, result)
+		}
+	}
+
+	if len(nums) != 1 {
+		return nil, fmt.Errorf("expected a single result, got %d operands", len(nums))
+	}
+
+	return nums[0], nil
+}
+
+// ratUnary applies a unary operator to x exactly.
+func ratUnary(op Operator, x *big.Rat) (*big.Rat, error) {
+	switch op {
+	case SQRT:
+		if x.Sign() < 0 {
+			return nil, fmt.Errorf("%w: square root of %s", ErrNotRational, x.RatString())
+		}
+
+		num, ok := exactSqrt(x.Num())
+		denom, ok2 := exactSqrt(x.Denom())
+
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%w: square root of %s", ErrNotRational, x.RatString())
+		}
+
+		return new(big.Rat).SetFrac(num, denom), nil
+	case NEG:
+		return new(big.Rat).Neg(x), nil
+	case RECIP:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		return new(big.Rat).Inv(x), nil
+	case FACT:
+		if !x.IsInt() {
+			return nil, fmt.Errorf("%w: factorial of %s", ErrDomain, x.RatString())
+		}
+
+		n, err := bigFactorial(x.Num())
+		if err != nil {
+			return nil, err
+		}
+
+		return new(big.Rat).SetInt(n), nil
+	case LN, EXP, SIN, COS, TAN:
+		return nil, fmt.Errorf("%w: %s of %s", ErrNotRational, op, x.RatString())
+	default:
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+}
+
+// bigFactorial returns n! exactly, returning ErrDomain if n is negative.
+func bigFactorial(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, fmt.Errorf("%w: factorial of %s", ErrDomain, n)
+	}
+
+	if !n.IsInt64() || n.Int64() > maxFactorial {
+		return nil, fmt.Errorf("factorial of %s is too large", n)
+	}
+
+	if n.Sign() == 0 {
+		return big.NewInt(1), nil
+	}
+
+	return new(big.Int).MulRange(1, n.Int64()), nil
+}
+
+// ratBinary applies a binary operator to y and x exactly, where x was on top of the stack.
+func ratBinary(op Operator, y, x *big.Rat) (*big.Rat, error) {
+	switch op {
+	case ADD:
+		return new(big.Rat).Add(y, x), nil
+	case SUB:
+		return new(big.Rat).Sub(y, x), nil
+	case MUL:
+		return new(big.Rat).Mul(y, x), nil
+	case DIV:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		return new(big.Rat).Quo(y, x), nil
+	case MOD:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		// Matches math.Mod: y - x*q, where q is y/x truncated towards zero.
+		quotient := new(big.Rat).Quo(y, x)
+		truncated := new(big.Rat).SetInt(new(big.Int).Quo(quotient.Num(), quotient.Denom()))
+
+		return new(big.Rat).Sub(y, truncated.Mul(truncated, x)), nil
+	case POW:
+		if !x.IsInt() {
+			return nil, fmt.Errorf("%w: fractional exponent %s", ErrNotRational, x.RatString())
+		}
+
+		if !x.Num().IsInt64() || x.Num().Int64() > maxRatExponent || x.Num().Int64() < -maxRatExponent {
+			return nil, fmt.Errorf("exponent %s is too large", x.RatString())
+		}
+
+		exponent := x.Num().Int64()
+		base := y
+
+		if exponent < 0 {
+			if y.Sign() == 0 {
+				return nil, ErrDivisionByZero
+			}
+
+			base = new(big.Rat).Inv(y)
+			exponent = -exponent
+		}
+
+		e := big.NewInt(exponent)
+		num := new(big.Int).Exp(base.Num(), e, nil)
+		denom := new(big.Int).Exp(base.Denom(), e, nil)
+
+		return new(big.Rat).SetFrac(num, denom), nil
+	case ROOT:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if !x.IsInt() || !x.Num().IsInt64() || x.Num().Int64() < 0 || x.Num().Int64() > maxRatExponent {
+			return nil, fmt.Errorf("%w: root %s of %s", ErrNotRational, x.RatString(), y.RatString())
+		}
+
+		degree := x.Num().Int64()
+		if y.Sign() < 0 && degree%2 == 0 {
+			return nil, fmt.Errorf("%w: root %d of %s", ErrNotRational, degree, y.RatString())
+		}
+
+		num, ok := exactRoot(new(big.Int).Abs(y.Num()), degree)
+		denom, ok2 := exactRoot(y.Denom(), degree)
+
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%w: root %d of %s", ErrNotRational, degree, y.RatString())
+		}
+
+		if y.Sign() < 0 {
+			num.Neg(num)
+		}
+
+		return new(big.Rat).SetFrac(num, denom), nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+}
+
+// exactRoot returns the kth root of the non-negative number n if it's a whole number, found by binary search.
+func exactRoot(n *big.Int, k int64) (*big.Int, bool) {
+	exponent := big.NewInt(k)
+	lo, hi := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(n.BitLen())/uint(k)+1)
+	mid, power := new(big.Int), new(big.Int)
+
+	for lo.Cmp(hi) <= 0 {
+		mid.Add(lo, hi).Rsh(mid, 1)
+
+		switch power.Exp(mid, exponent, nil).Cmp(n) {
+		case 0:
+			return mid, true
+		case -1:
+			lo.Add(mid, big.NewInt(1))
+		case 1:
+			hi.Sub(mid, big.NewInt(1))
+		}
+	}
+
+	return nil, false
+}
+
+// exactSqrt returns the square root of n if n is a perfect square.
+func exactSqrt(n *big.Int) (*big.Int, bool) {
+	root := new(big.Int).Sqrt(n)
+
+	return root, new(big.Int).Mul(root, root).Cmp(n) == 0
+}
+
+// bigConstants holds the decimal expansions of constants used by EvaluateBig, to 100 decimal places.
+var bigConstants = map[Constant]string{
+	PI: "3.1415926535897932384626433832795028841971693993751058209749445923078164062862089986280348253421170679",
+	E:  "2.7182818284590452353602874713526624977572470936999595749669676277240766303535475945713821785251664274",
+}
+
+// EvaluateBig evaluates a stack of atoms in postfix notation using arbitrary-precision floats with prec bits of
+// mantissa. Powers are only supported for whole-number exponents and ROOT only for square roots, and LN, EXP, SIN, COS
+// and TAN aren't supported at all, returning an error rather than a rounded result, as are NaN and infinite numbers.
+// Constants are only accurate to 100 decimal places (roughly 330 bits) regardless of prec.
+func EvaluateBig(s *Stack, prec uint) (*big.Float, error) {
+	nums := []*big.Float{}
+
+	for _, atom := range s.items {
+		switch atom := atom.(type) {
+		case Number:
+			// big.Float can hold infinities, but panics on operations like Inf - Inf rather than returning NaN.
+			if !finite(float64(atom)) {
+				return nil, fmt.Errorf("%v isn't supported at arbitrary precision", atom)
+			}
+
+			// As in EvaluateRat, numbers are read from their shortest decimal form rather than their float value.
+			num, _, err := big.ParseFloat(fmt.Sprint(float64(atom)), 10, prec, big.ToNearestEven)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't convert %v: %w", atom, err)
+			}
+
+			nums = append(nums, num)
+		case Constant:
+			num, _, err := big.ParseFloat(bigConstants[atom], 10, prec, big.ToNearestEven)
+			if err != nil {
+				return nil, fmt.Errorf("unknown constant %s", atom)
+			}
+
+			nums = append(nums, num)
+		case Variable:
+			return nil, fmt.Errorf("%w %s", ErrUnboundVariable, atom)
+		case Operator:
+			if len(nums) < valence(atom) {
+				return nil, fmt.Errorf("%w at operator %s", ErrStackUnderflow, atom)
+			}
+
+			if valence(atom) == 1 {
+				x := nums[len(nums)-1]
+
+				result, err := bigUnary(atom, x, prec)
+				if err != nil {
+					return nil, err
+				}
+
+				nums[len(nums)-1] = result
+				continue
+			}
+
+			x, y := nums[len(nums)-1], nums[len(nums)-2]
+			nums = nums[:len(nums)-2]
+
+			result, err := bigBinary(atom, y, x, prec)
+			if err != nil {
+				return nil, err
+			}
+
+			nums = append(nums, result)
+		}
+	}
+
+	if len(nums) != 1 {
+		return nil, fmt.Errorf("expected a single result, got %d operands", len(nums))
+	}
+
+	return nums[0], nil
+}
+
+// bigUnary applies a unary operator to x at the given precision.
+func bigUnary(op Operator, x *big.Float, prec uint) (*big.Float, error) {
+	switch op {
+	case SQRT:
+		if x.Sign() < 0 {
+			return nil, fmt.Errorf("square root of negative number %s", x.String())
+		}
+
+		return new(big.Float).SetPrec(prec).Sqrt(x), nil
+	case NEG:
+		return new(big.Float).SetPrec(prec).Neg(x), nil
+	case RECIP:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		return new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), x), nil
+	case FACT:
+		if !x.IsInt() {
+			return nil, fmt.Errorf("%w: factorial of %s", ErrDomain, x.String())
+		}
+
+		i, _ := x.Int(nil)
+
+		n, err := bigFactorial(i)
+		if err != nil {
+			return nil, err
+		}
+
+		return new(big.Float).SetPrec(prec).SetInt(n), nil
+	case LN, EXP, SIN, COS, TAN:
+		return nil, fmt.Errorf("%s isn't supported at arbitrary precision", op)
+	default:
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+}
+
+// bigBinary applies a binary operator to y and x at the given precision, where x was on top of the stack.
+func bigBinary(op Operator, y, x *big.Float, prec uint) (*big.Float, error) {
+	result := new(big.Float).SetPrec(prec)
+
+	switch op {
+	case ADD:
+		return result.Add(y, x), nil
+	case SUB:
+		return result.Sub(y, x), nil
+	case MUL:
+		return result.Mul(y, x), nil
+	case DIV:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		return result.Quo(y, x), nil
+	case MOD:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		// Matches math.Mod: y - x*q, where q is y/x truncated towards zero.
+		quotient, _ := new(big.Float).SetPrec(prec).Quo(y, x).Int(nil)
+		truncated := new(big.Float).SetPrec(prec).SetInt(quotient)
+
+		return result.Sub(y, truncated.Mul(truncated, x)), nil
+	case POW:
+		if !x.IsInt() {
+			return nil, fmt.Errorf("fractional exponent %s is not supported", x.String())
+		}
+
+		exponent, accuracy := x.Int64()
+		if accuracy != big.Exact || exponent > maxRatExponent || exponent < -maxRatExponent {
+			return nil, fmt.Errorf("exponent %s is too large", x.String())
+		}
+
+		negative := exponent < 0
+		if negative {
+			exponent = -exponent
+		}
+
+		// Exponentiation by squaring.
+		result.SetInt64(1)
+		base := new(big.Float).SetPrec(prec).Set(y)
+
+		for ; exponent > 0; exponent >>= 1 {
+			if exponent&1 == 1 {
+				result.Mul(result, base)
+			}
+
+			base.Mul(base, base)
+		}
+
+		if negative {
+			if result.Sign() == 0 {
+				return nil, ErrDivisionByZero
+			}
+
+			result.Quo(new(big.Float).SetPrec(prec).SetInt64(1), result)
+		}
+
+		return result, nil
+	case ROOT:
+		if x.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if x.Cmp(big.NewFloat(2)) != 0 {
+			return nil, fmt.Errorf("root %s isn't supported at arbitrary precision, only square roots", x.String())
+		}
+
+		if y.Sign() < 0 {
+			return nil, fmt.Errorf("square root of negative number %s", y.String())
+		}
+
+		return result.Sqrt(y), nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %s", op)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestEvaluateBigPrecision(t *testing.T) {
+	// 10^16 + 1 can't be represented as a float64, so it's rounded back down to 10^16 and the 1 is lost.
+	s, err := Parse("10 16 ^ 1 + 10 16 ^ -")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := Evaluate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != 0 {
+		t.Errorf("Evaluate(%q) = %v, want float64 rounding to give 0", s, val)
+	}
+
+	bigVal, err := EvaluateBig(s, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bigVal.Cmp(big.NewFloat(1)) != 0 {
+		t.Errorf("EvaluateBig(%q, 200) = %v, want 1", s, bigVal)
+	}
+}
+
+func TestEvaluateBigNonFinite(t *testing.T) {
+	for _, expression := range []string{"inf", "inf inf -", "inf 0 *", "inf 2 %", "-inf √", "NaN 1 +"} {
+		s, err := Parse(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if val, err := EvaluateBig(s, 64); err == nil {
+			t.Errorf("EvaluateBig(%q, 64) = %v, want an error", expression, val)
+		}
+	}
+}
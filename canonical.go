@@ -0,0 +1,31 @@
+package main
+
+// Canonical returns a copy of the expression with the operands of commutative operators (ADD and MUL) sorted into a
+// deterministic order, so that equivalent expressions like "3 4 +" and "4 3 +" share the same canonical form. Other
+// algebraic identities, such as associativity, aren't taken into account. Invalid expressions are returned unchanged.
+func (s *Stack) Canonical() *Stack {
+	if !s.Valid() {
+		return s.Clone()
+	}
+
+	subexpressions := [][]Atom{}
+
+	for _, atom := range s.items {
+		n := valence(atom)
+		operands := subexpressions[len(subexpressions)-n:]
+		subexpressions = subexpressions[:len(subexpressions)-n]
+
+		if (atom == ADD || atom == MUL) && NewStack(operands[1]...).String() < NewStack(operands[0]...).String() {
+			operands[0], operands[1] = operands[1], operands[0]
+		}
+
+		combined := []Atom{}
+		for _, operand := range operands {
+			combined = append(combined, operand...)
+		}
+
+		subexpressions = append(subexpressions, append(combined, atom))
+	}
+
+	return NewStack(subexpressions[0]...)
+}
@@ -0,0 +1,44 @@
+package main
+
+// ComplexityWeights is how much each operator contributes to an expression's complexity. Operators that aren't listed
+// have a weight of 1, as do all numbers and constants. It can be changed to tune which expressions count as simpler.
+var ComplexityWeights = map[Operator]int{
+	ADD:   1,
+	SUB:   1,
+	MUL:   1,
+	DIV:   1,
+	NEG:   1,
+	SQRT:  2,
+	MOD:   2,
+	POW:   2,
+	ROOT:  2,
+	LN:    3,
+	EXP:   3,
+	SIN:   3,
+	COS:   3,
+	TAN:   3,
+	FACT:  2,
+	RECIP: 1,
+}
+
+// Complexity scores how complicated the expression is, as the sum of the weights of its atoms from
+// ComplexityWeights. Lower scores are simpler.
+func (s *Stack) Complexity() int {
+	complexity := 0
+
+	for _, atom := range s.items {
+		op, ok := atom.(Operator)
+		if !ok {
+			complexity++
+			continue
+		}
+
+		if weight, ok := ComplexityWeights[op]; ok {
+			complexity += weight
+		} else {
+			complexity++
+		}
+	}
+
+	return complexity
+}
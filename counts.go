@@ -0,0 +1,78 @@
+package main
+
+import "strings"
+
+// OperandCount returns the number of numbers, constants and variables in the expression.
+func (s *Stack) OperandCount() int {
+	return s.Len() - s.OperatorCount()
+}
+
+// OperatorCount returns the number of operators in the expression, counting repeats. For a valid expression, this is
+// one less than OperandCount if every operator is binary.
+func (s *Stack) OperatorCount() int {
+	count := 0
+
+	for _, atom := range s.items {
+		if atom.IsOperator() {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Profile returns the shape of the expression with its operands left out, such as "_ _ + _ *" for "3 4 + 5 *", so
+// that expressions differing only in their numbers have the same profile.
+func (s *Stack) Profile() string {
+	var b strings.Builder
+
+	for i, atom := range s.items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		if op, ok := atom.(Operator); ok {
+			b.WriteString(string(op))
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+// OperatorCounts returns how many times each operator appears in the expression. Operators that don't appear aren't
+// included.
+func (s *Stack) OperatorCounts() map[Operator]int {
+	counts := make(map[Operator]int)
+
+	for _, atom := range s.items {
+		if op, ok := atom.(Operator); ok {
+			counts[op]++
+		}
+	}
+
+	return counts
+}
+
+// WithinCounts returns true if no operator appears in the expression more often than its limit, such as
+// map[Operator]int{SQRT: 2, DIV: 1} for at most two square roots and one division. Operators without a limit can
+// appear any number of times.
+func (s *Stack) WithinCounts(limits map[Operator]int) bool {
+	// Counting each limited operator separately avoids allocating a map for every expression checked during a search.
+	for op, limit := range limits {
+		count := 0
+
+		for _, atom := range s.items {
+			if atom == op {
+				count++
+			}
+		}
+
+		if count > limit {
+			return false
+		}
+	}
+
+	return true
+}
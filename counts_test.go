@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCountsAddUpToLen(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	opts := GenerateOptions{Fractions: true, Trig: true}
+
+	for i := 0; i < 1000; i++ {
+		s := GenerateInRange(r, r.Intn(20)+1, 1, 9, opts)
+
+		if s.OperandCount()+s.OperatorCount() != s.Len() {
+			t.Fatalf("%q has %d operands and %d operators, but %d atoms", s, s.OperandCount(), s.OperatorCount(), s.Len())
+		}
+	}
+
+	s, err := Parse("3 4 + pi √ * x -")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.OperandCount() != 4 || s.OperatorCount() != 4 {
+		t.Errorf("%q: got %d operands and %d operators, want 4 and 4", s, s.OperandCount(), s.OperatorCount())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxMatchingDecimals is the most decimal places MatchingDecimals will count, which is about as many as a float64 can
+// hold for numbers near one.
+const MaxMatchingDecimals = 15
+
+// MatchingDecimals returns the number of digits after the decimal point that a and b share before they first differ,
+// up to MaxMatchingDecimals. It returns -1 if they differ before the decimal point, including if they have different
+// signs, or if either of them is NaN or infinite.
+//
+// The digits are compared after rounding both numbers to MaxMatchingDecimals places, beyond which a float64 can't
+// reliably tell numbers apart, but before any other rounding. So 3.1499999 and 3.15 only match to 1 decimal place, even
+// though they'd be identical if rounded to 5, while 0.1+0.2 and 0.3 match to every place despite differing in
+// their last bit.
+func MatchingDecimals(a, b float64) int {
+	if !finite(a) || !finite(b) {
+		return -1
+	}
+
+	// Negative zero would otherwise be written with a minus sign, and so not match zero.
+	if a == 0 {
+		a = 0
+	}
+
+	if b == 0 {
+		b = 0
+	}
+
+	x := strconv.FormatFloat(a, 'f', MaxMatchingDecimals, 64)
+	y := strconv.FormatFloat(b, 'f', MaxMatchingDecimals, 64)
+
+	if len(x) != len(y) {
+		return -1
+	}
+
+	matched := -1
+
+	for i := range x {
+		if x[i] != y[i] {
+			break
+		}
+
+		if matched >= 0 || x[i] == '.' {
+			matched++
+		}
+	}
+
+	return matched
+}
+
+// matchedDigits returns the number of leading digits of value that match the target, counting the digits of the integer
+// part as well as those after the decimal point, so 3.14161 matches π to 4 digits. It returns 0 if the integer parts
+// differ, including if the numbers have different signs.
+func matchedDigits(target, value float64) int {
+	decimals := MatchingDecimals(target, value)
+	if decimals < 0 {
+		return 0
+	}
+
+	// The integer parts are the same, so either one can be used to count their digits.
+	integer := strconv.FormatFloat(math.Trunc(target), 'f', 0, 64)
+
+	return len(strings.TrimPrefix(integer, "-")) + decimals
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatchingDecimals(t *testing.T) {
+	tests := []struct {
+		a, b float64
+		want int
+	}{
+		{3.1499999, 3.15, 1},
+		{3.15, 3.1499999, 1},
+		{3.14159, 3.14161, 3},
+		{math.Pi, math.Pi, MaxMatchingDecimals},
+		{0.1 + 0.2, 0.3, MaxMatchingDecimals},
+		{3.9, 4.1, -1},
+		{10.5, 1.5, -1},
+		{0.5, -0.5, -1},
+		{math.Copysign(0, -1), 0, MaxMatchingDecimals},
+		{0, math.Copysign(0, -1), MaxMatchingDecimals},
+		{math.NaN(), math.NaN(), -1},
+		{math.NaN(), 1, -1},
+		{math.Inf(1), math.Inf(1), -1},
+	}
+
+	for _, test := range tests {
+		if got := MatchingDecimals(test.a, test.b); got != test.want {
+			t.Errorf("MatchingDecimals(%v, %v) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import "context"
+
+// EnumerateExpressions sends every valid expression of exactly the given length on the returned channel, in a fixed
+// order, and closes it once they've all been sent. The expressions use whole numbers between minNum and maxNum
+// inclusive and the same operators as expressions generated with the default GenerateOptions: RandomOperators and
+// SQRT.
+//
+// Unlike random generation, this is guaranteed to find the best approximation of a given length, but the number of
+// expressions grows exponentially with the length. With the default numbers 1 to 9, the four default binary operators
+// and SQRT, there are 981 expressions of length 4, about 2.5 million of length 7 and over 2 billion of length 10, so
+// it's only practical for lengths up to about 8 or for a narrower range of numbers.
+//
+// Every expression must be received from the channel, or the goroutine sending them will block forever; use
+// EnumerateExpressionsContext to be able to stop early.
+func EnumerateExpressions(length, minNum, maxNum int) <-chan *Stack {
+	return EnumerateExpressionsContext(context.Background(), length, minNum, maxNum, GenerateOptions{})
+}
+
+// EnumerateExpressionsContext is like EnumerateExpressions, but stops sending expressions and closes the channel once
+// the context is cancelled. The expressions use the same operators as expressions generated with opts: those with
+// positive weights in OperatorWeights if there are any, along with the trigonometric operators if Trig is set. Since
+// only whole numbers are enumerated, Fractions is ignored.
+func EnumerateExpressionsContext(ctx context.Context, length, minNum, maxNum int, opts GenerateOptions) <-chan *Stack {
+	expressions := make(chan *Stack)
+	binary, unary := opts.operators()
+
+	numbers := []Atom{}
+	for n := minNum; n <= maxNum; n++ {
+		numbers = append(numbers, Number(n))
+	}
+
+	go func() {
+		defer close(expressions)
+
+		items := make([]Atom, 0, length)
+
+		// canFinish returns true if an expression with size operands on the stack can be completed in exactly the
+		// given number of atoms.
+		canFinish := func(size, remaining int) bool {
+			if size < 1 || size-1 > remaining {
+				return false
+			}
+
+			// Without unary operators, every atom beyond the binary operators needed to reduce the stack to a single
+			// operand has to be an operand paired with another binary operator.
+			return len(unary) > 0 || (remaining-(size-1))%2 == 0
+		}
+
+		// enumerate tries every atom at the next position, given the number of operands on the stack so far,
+		// returning false if the context was cancelled.
+		var enumerate func(size int) bool
+		enumerate = func(size int) bool {
+			remaining := length - len(items)
+			if remaining == 0 {
+				select {
+				case expressions <- NewStack(append([]Atom(nil), items...)...):
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+
+			try := func(atoms []Atom, newSize int) bool {
+				if !canFinish(newSize, remaining-1) {
+					return true
+				}
+
+				for _, atom := range atoms {
+					items = append(items, atom)
+					ok := enumerate(newSize)
+					items = items[:len(items)-1]
+
+					if !ok {
+						return false
+					}
+				}
+
+				return true
+			}
+
+			return try(numbers, size+1) && try(unary, size) && try(binary, size-1)
+		}
+
+		if length > 0 && len(numbers) > 0 {
+			enumerate(0)
+		}
+	}()
+
+	return expressions
+}
+
+// operators returns the binary and unary operators that generated expressions can contain.
+func (g GenerateOptions) operators() (binary, unary []Atom) {
+	unaryOps := []Operator{SQRT}
+	if g.Trig {
+		unaryOps = append(unaryOps, SIN, COS, TAN)
+	}
+
+	binary = g.weightedOrDefault(binaryOperator, RandomOperators)
+	unary = g.weightedOrDefault(unaryOperator, unaryOps)
+
+	return binary, unary
+}
+
+// weightedOrDefault returns the operators matching the predicate that have a positive weight in OperatorWeights, or
+// the defaults if there aren't any.
+func (g GenerateOptions) weightedOrDefault(matches func(Operator) bool, defaults []Operator) []Atom {
+	atoms := []Atom{}
+
+	for _, op := range operators {
+		if g.OperatorWeights[op] > 0 && matches(op) {
+			atoms = append(atoms, op)
+		}
+	}
+
+	if len(atoms) > 0 {
+		return atoms
+	}
+
+	for _, op := range defaults {
+		atoms = append(atoms, op)
+	}
+
+	return atoms
+}
@@ -0,0 +1,59 @@
+package main
+
+// IsConstant returns true if the expression doesn't contain any variables, so that it always evaluates to the same
+// value.
+func (s *Stack) IsConstant() bool {
+	for _, atom := range s.items {
+		if _, ok := atom.(Variable); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fold returns a copy of the expression with every constant subexpression replaced by its value. For example,
+// "x 2 3 * +" is folded to "x 6 +", and an expression without any variables is folded to a single number. Lone
+// constants like pi are kept as they are, as are subexpressions that can't be evaluated to a finite number, such as
+// "1 0 /". Invalid expressions are returned unchanged.
+func (s *Stack) Fold() *Stack {
+	tree, err := s.Tree()
+	if err != nil {
+		return s.Clone()
+	}
+
+	return tree.fold().Stack()
+}
+
+// fold returns a copy of the node with its constant subtrees replaced by their values.
+func (n *Node) fold() *Node {
+	op, ok := n.Atom.(Operator)
+	if !ok {
+		return &Node{Atom: n.Atom}
+	}
+
+	children := make([]*Node, len(n.Children))
+	args := make([]float64, len(n.Children))
+	constant := true
+
+	for i, child := range n.Children {
+		children[i] = child.fold()
+
+		switch atom := children[i].Atom.(type) {
+		case Number:
+			args[i] = float64(atom)
+		case Constant:
+			args[i] = float64(atom.Value())
+		default:
+			constant = false
+		}
+	}
+
+	if constant {
+		if val, err := operatorTable[op].eval(args); err == nil && finite(val) {
+			return &Node{Atom: Number(val)}
+		}
+	}
+
+	return &Node{Atom: op, Children: children}
+}
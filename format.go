@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumberStyle is a way of writing the numbers in an expression.
+type NumberStyle int
+
+const (
+	// Shortest writes each number with the fewest digits that parse back to exactly the same value, switching to an
+	// exponent for very large or small numbers, such as 0.5 or 1e+21.
+	Shortest NumberStyle = iota
+
+	// FixedPoint writes each number with exactly FormatOptions.Decimals digits after the decimal point, such as 0.500.
+	FixedPoint
+
+	// Scientific writes each number as a mantissa with FormatOptions.Decimals digits after the decimal point and an
+	// exponent, such as 5.00e-01.
+	Scientific
+)
+
+// FormatOptions controls how Format writes an expression. The zero value formats the same way as String, except that
+// square roots are always written as "√".
+type FormatOptions struct {
+	// Numbers is the style numbers are written in.
+	Numbers NumberStyle
+
+	// Decimals is the number of digits after the decimal point for the FixedPoint and Scientific styles. It's ignored
+	// by Shortest.
+	Decimals int
+
+	// ASCIISqrt writes square roots as "sqrt" rather than "√", and reciprocals as "recip" rather than "⁻¹", for
+	// terminals and editors that struggle with unicode. Parse accepts either form.
+	ASCIISqrt bool
+}
+
+// Format writes the expression in postfix notation, like String, but with control over how numbers are written. Only
+// the Shortest style is guaranteed to parse back to an identical expression, since the others can round numbers.
+func (s *Stack) Format(opts FormatOptions) string {
+	var out []string
+
+	for _, atom := range s.items {
+		switch atom := atom.(type) {
+		case Operator:
+			if opts.ASCIISqrt {
+				switch atom {
+				case SQRT:
+					out = append(out, "sqrt")
+					continue
+				case RECIP:
+					out = append(out, "recip")
+					continue
+				}
+			}
+
+			out = append(out, string(atom))
+		case Constant:
+			out = append(out, string(atom))
+		case Variable:
+			out = append(out, string(atom))
+		case Number:
+			out = append(out, formatNumber(atom, opts))
+		}
+	}
+
+	return strings.Join(out, " ")
+}
+
+// formatNumber writes a single number in the style given by the options.
+func formatNumber(n Number, opts FormatOptions) string {
+	switch opts.Numbers {
+	case FixedPoint:
+		return strconv.FormatFloat(float64(n), 'f', opts.Decimals, 64)
+	case Scientific:
+		return strconv.FormatFloat(float64(n), 'e', opts.Decimals, 64)
+	default:
+		// A precision of -1 gives the shortest representation that ParseFloat reads back as exactly the same float, so
+		// that Parse(s.String()) reproduces s.
+		return strconv.FormatFloat(float64(n), 'g', -1, 64)
+	}
+}
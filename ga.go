@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// GAOptions configures a genetic algorithm search. Zero values are replaced with sensible defaults.
+type GAOptions struct {
+	// Precision is the number of decimal places an expression must match the target to.
+	Precision int
+
+	// Population is the number of individuals in each generation, defaulting to 200.
+	Population int
+
+	// Generations is the number of generations to evolve before stopping. The search runs until the context is
+	// cancelled if it is zero.
+	Generations int
+
+	// MinLength and MaxLength bound the number of atoms in the initial population, defaulting to 5 and 15. Offspring
+	// can grow up to twice MaxLength.
+	MinLength int
+	MaxLength int
+
+	// MinNum and MaxNum are the inclusive bounds on the whole numbers in the initial population. If both are zero,
+	// DefaultMinNum and DefaultMaxNum are used instead.
+	MinNum int
+	MaxNum int
+
+	// MutationRate is the probability that an offspring is mutated, defaulting to 0.2.
+	MutationRate float64
+
+	// Elites is the number of fittest individuals copied unchanged into the next generation, defaulting to 2.
+	Elites int
+
+	// Rand is the source of randomness, defaulting to the global source.
+	Rand *rand.Rand
+
+	// GenerateOptions controls the operators and numbers in the initial population and in mutations.
+	GenerateOptions
+}
+
+// withDefaults returns the options with any zero values replaced by their defaults.
+func (opts GAOptions) withDefaults() GAOptions {
+	if opts.Population == 0 {
+		opts.Population = 200
+	}
+
+	if opts.MinLength == 0 {
+		opts.MinLength = 5
+	}
+
+	if opts.MaxLength == 0 {
+		opts.MaxLength = 15
+	}
+
+	if opts.MaxLength < opts.MinLength {
+		opts.MaxLength = opts.MinLength
+	}
+
+	if opts.MinNum == 0 && opts.MaxNum == 0 {
+		opts.MinNum, opts.MaxNum = DefaultMinNum, DefaultMaxNum
+	}
+
+	if opts.MutationRate == 0 {
+		opts.MutationRate = 0.2
+	}
+
+	if opts.Elites == 0 {
+		opts.Elites = 2
+	}
+
+	if opts.Elites > opts.Population {
+		opts.Elites = opts.Population
+	}
+
+	if opts.Rand == nil {
+		opts.Rand = globalRand
+	}
+
+	return opts
+}
+
+// individual is a member of the population along with its distance from the target.
+type individual struct {
+	expression *Stack
+	diff       float64
+}
+
+// EvolveSearch searches for approximations to the target using a genetic algorithm. Each generation is bred from the
+// previous one by selecting fit parents, swapping subtrees between them and occasionally mutating the offspring, where
+// an expression's fitness is 1/(diff+epsilon). Expressions within the precision are sent on the returned channel the
+// first time they appear, and the channel is closed once the context is cancelled or the last generation is done.
+func EvolveSearch(ctx context.Context, target float64, opts GAOptions) <-chan Result {
+	opts = opts.withDefaults()
+	epsilon := math.Pow10(-opts.Precision)
+	results := make(chan Result)
+
+	go func() {
+		defer close(results)
+
+		r := opts.Rand
+		seen := make(map[string]struct{})
+
+		evaluate := func(expression *Stack) individual {
+			val, err := Evaluate(expression)
+			if err != nil || !finite(val) {
+				return individual{expression: expression, diff: math.Inf(1)}
+			}
+
+			return individual{expression: expression, diff: math.Abs(target - val)}
+		}
+
+		population := make([]individual, opts.Population)
+		for i := range population {
+			population[i] = evaluate(GenerateInRange(r, r.Intn(opts.MaxLength-opts.MinLength+1)+opts.MinLength, opts.MinNum, opts.MaxNum, opts.GenerateOptions))
+		}
+
+		for generation := 0; opts.Generations == 0 || generation < opts.Generations; generation++ {
+			sort.Slice(population, func(i, j int) bool { return population[i].diff < population[j].diff })
+
+			for _, ind := range population {
+				if ind.diff >= epsilon {
+					break
+				}
+
+				key := ind.expression.Canonical().String()
+				if _, ok := seen[key]; ok {
+					continue
+				}
+
+				seen[key] = struct{}{}
+
+				val, _ := Evaluate(ind.expression)
+
+				select {
+				case results <- Result{Diff: ind.diff, Value: val, Expression: ind.expression}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			next := make([]individual, 0, opts.Population)
+			next = append(next, population[:opts.Elites]...)
+
+			for len(next) < opts.Population {
+				child := crossover(r, tournament(r, population).expression, tournament(r, population).expression)
+
+				if r.Float64() < opts.MutationRate {
+					child = neighbour(r, child, 1, opts.GenerateOptions)
+				}
+
+				if !child.Valid() || child.Len() > 2*opts.MaxLength {
+					continue
+				}
+
+				next = append(next, evaluate(child))
+			}
+
+			population = next
+		}
+	}()
+
+	return results
+}
+
+// tournament picks a few random individuals and returns the fittest of them.
+func tournament(r *rand.Rand, population []individual) individual {
+	best := population[r.Intn(len(population))]
+
+	for i := 0; i < 2; i++ {
+		if challenger := population[r.Intn(len(population))]; challenger.diff < best.diff {
+			best = challenger
+		}
+	}
+
+	return best
+}
+
+// crossover returns a copy of a with a random subtree replaced by a random subtree of b. Since both subtrees are
+// complete expressions, the result is valid whenever a and b are.
+func crossover(r *rand.Rand, a, b *Stack) *Stack {
+	bEnd := r.Intn(b.Len())
+	bStart := subtreeStart(b.items, bEnd)
+
+	if bStart < 0 {
+		return a.Clone()
+	}
+
+	child, err := a.ReplaceSubtree(r.Intn(a.Len()), NewStack(b.items[bStart:bEnd+1]...))
+	if err != nil {
+		return a.Clone()
+	}
+
+	return child
+}
+
+// subtreeStart returns the index of the first atom of the subexpression whose last atom is at end, or -1 if there
+// isn't a complete subexpression ending there.
+func subtreeStart(items []Atom, end int) int {
+	need := 1
+
+	for i := end; i >= 0; i-- {
+		need += valence(items[i]) - 1
+
+		if need == 0 {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// infixFunctions are the operators that are written before their operands in infix notation, like "√2", "sin(x)" or
+// "root(27, 3)". Functions of two operands must have their arguments in parentheses, separated by a comma.
+var infixFunctions = map[string]Operator{
+	"√":    SQRT,
+	"sqrt": SQRT,
+	"ln":   LN,
+	"exp":  EXP,
+	"sin":  SIN,
+	"cos":  COS,
+	"tan":  TAN,
+	"root": ROOT,
+}
+
+// infixOperator is an entry on the operator stack used by ParseInfix, which is either an operator or an opening
+// parenthesis. Function records whether the operator was written as a function, and commas counts the commas seen so
+// far inside a parenthesis.
+type infixOperator struct {
+	op       Operator
+	paren    bool
+	function bool
+	commas   int
+	offset   int
+}
+
+// ParseInfix parses an expression in conventional infix notation, such as "(3 + 4) * √5", to a stack using the
+// shunting-yard algorithm. Operators follow the usual precedence rules, with exponentiation being right-associative.
+// A minus sign with nothing to its left is parsed as negation, and an exclamation mark after an operand is parsed as
+// its factorial. The arguments to root are in the same order as in postfix, so "root(27, 3)" is the cube root of 27.
+func ParseInfix(expression string) (*Stack, error) {
+	tokens, err := tokenizeInfix(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []Atom{}
+	operators := []infixOperator{}
+	expectOperand := true
+
+	// popWhile moves operators from the operator stack to the output while keep returns true.
+	popWhile := func(keep func(top infixOperator) bool) {
+		for len(operators) > 0 && !operators[len(operators)-1].paren && keep(operators[len(operators)-1]) {
+			output = append(output, operators[len(operators)-1].op)
+			operators = operators[:len(operators)-1]
+		}
+	}
+
+	// function returns the function whose arguments are in the innermost open parenthesis, if there is one.
+	function := func() (infixOperator, bool) {
+		if len(operators) < 2 || !operators[len(operators)-2].function {
+			return infixOperator{}, false
+		}
+
+		return operators[len(operators)-2], true
+	}
+
+	for i, tok := range tokens {
+		if expectOperand {
+			switch {
+			case tok.text == "(":
+				operators = append(operators, infixOperator{paren: true, offset: tok.offset})
+			case tok.text == "-":
+				operators = append(operators, infixOperator{op: NEG, offset: tok.offset})
+			case infixFunctions[tok.text] != "":
+				op := infixFunctions[tok.text]
+				if op.Arity() == 2 && (i+1 == len(tokens) || tokens[i+1].text != "(") {
+					return nil, fmt.Errorf("expected '(' after %q at offset %d", tok.text, tok.offset)
+				}
+
+				operators = append(operators, infixOperator{op: op, function: true, offset: tok.offset})
+			default:
+				atom, err := parseAtom(tok.text)
+				if err != nil || atom.IsOperator() {
+					return nil, fmt.Errorf("expected a number at offset %d, got %q", tok.offset, tok.text)
+				}
+
+				output = append(output, atom)
+				expectOperand = false
+			}
+
+			continue
+		}
+
+		switch tok.text {
+		case ")":
+			popWhile(func(infixOperator) bool { return true })
+
+			if len(operators) == 0 {
+				return nil, fmt.Errorf("unmatched ')' at offset %d", tok.offset)
+			}
+
+			if f, ok := function(); ok && operators[len(operators)-1].commas != f.op.Arity()-1 {
+				return nil, fmt.Errorf("%s at offset %d takes %d arguments", f.op, f.offset, f.op.Arity())
+			}
+
+			operators = operators[:len(operators)-1]
+		case ",":
+			popWhile(func(infixOperator) bool { return true })
+
+			if f, ok := function(); !ok || operators[len(operators)-1].commas == f.op.Arity()-1 {
+				return nil, fmt.Errorf("unexpected ',' at offset %d", tok.offset)
+			}
+
+			operators[len(operators)-1].commas++
+			expectOperand = true
+		case string(FACT):
+			// Factorials follow their operand and bind tighter than anything else, so they can go straight to the
+			// output.
+			output = append(output, FACT)
+		default:
+			atom, err := parseAtom(tok.text)
+			op, ok := atom.(Operator)
+
+			if err != nil || !ok || op.Arity() != 2 {
+				return nil, fmt.Errorf("expected an operator at offset %d, got %q", tok.offset, tok.text)
+			}
+
+			popWhile(func(top infixOperator) bool {
+				if op == POW {
+					return top.op.Precedence() > op.Precedence()
+				}
+
+				return top.op.Precedence() >= op.Precedence()
+			})
+
+			operators = append(operators, infixOperator{op: op, offset: tok.offset})
+			expectOperand = true
+		}
+	}
+
+	if expectOperand {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	for len(operators) > 0 {
+		top := operators[len(operators)-1]
+		if top.paren {
+			return nil, fmt.Errorf("unmatched '(' at offset %d", top.offset)
+		}
+
+		output = append(output, top.op)
+		operators = operators[:len(operators)-1]
+	}
+
+	return NewStack(output...), nil
+}
+
+// infixToken is a single token of an infix expression, along with its byte offset in the expression.
+type infixToken struct {
+	text   string
+	offset int
+}
+
+// tokenizeInfix splits an infix expression into numbers, names, operators, parentheses and commas, ignoring whitespace.
+func tokenizeInfix(expression string) ([]infixToken, error) {
+	tokens := []infixToken{}
+	runes := []rune(expression)
+	offset := 0
+
+	for i := 0; i < len(runes); {
+		start := i
+		c := runes[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+
+			// Scientific notation such as 1e-3, taking care not to swallow the constant e in "2e".
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+
+			if _, err := strconv.ParseFloat(string(runes[start:i]), 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", string(runes[start:i]), offset)
+			}
+		case unicode.IsLetter(c):
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+
+		text := string(runes[start:i])
+		if !unicode.IsSpace(c) {
+			tokens = append(tokens, infixToken{text: text, offset: offset})
+		}
+
+		offset += len(text)
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestParseInfixRoot(t *testing.T) {
+	tests := []struct {
+		infix   string
+		postfix string
+	}{
+		{"root(27, 3)", "27 3 root"},
+		{"root(27,3)", "27 3 root"},
+		{"2 * root(1 + 7, (3))", "2 1 7 + 3 root *"},
+		{"root(root(64, 2), 3)", "64 2 root 3 root"},
+		{"√root(16, 2)", "16 2 root √"},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseInfix(tt.infix)
+		if err != nil {
+			t.Errorf("ParseInfix(%q) failed: %v", tt.infix, err)
+			continue
+		}
+
+		if got := s.String(); got != tt.postfix {
+			t.Errorf("ParseInfix(%q) = %s, want %s", tt.infix, got, tt.postfix)
+		}
+	}
+
+	for _, infix := range []string{"root 27", "root(27)", "root(27, 3, 2)", "(27, 3)", "sin(1, 2)", "2, 3"} {
+		if s, err := ParseInfix(infix); err == nil {
+			t.Errorf("ParseInfix(%q) = %s, want an error", infix, s)
+		}
+	}
+}
+
+func TestInfixRoundTrip(t *testing.T) {
+	for _, postfix := range []string{"27 3 root", "2 3 + 8 3 root *", "64 2 3 + root √", "2 3 root ~"} {
+		s, err := Parse(postfix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		infix, err := s.Infix()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := ParseInfix(infix)
+		if err != nil {
+			t.Errorf("ParseInfix(%q) failed on the infix form of %s: %v", infix, postfix, err)
+			continue
+		}
+
+		if !parsed.Equal(s) {
+			t.Errorf("ParseInfix(%q) = %s, want %s", infix, parsed, postfix)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonAtom is the JSON representation of a single atom. Exactly one of the fields is set.
+type jsonAtom struct {
+	Op    *string  `json:"op,omitempty"`
+	Num   *float64 `json:"num,omitempty"`
+	Const *string  `json:"const,omitempty"`
+	Var   *string  `json:"var,omitempty"`
+}
+
+// MarshalJSON encodes the stack as an array of atoms in postfix order, such as [{"num":3},{"num":4},{"op":"+"}].
+func (s *Stack) MarshalJSON() ([]byte, error) {
+	atoms := make([]jsonAtom, 0, s.Len())
+
+	for _, atom := range s.items {
+		switch atom := atom.(type) {
+		case Operator:
+			op := string(atom)
+			atoms = append(atoms, jsonAtom{Op: &op})
+		case Number:
+			num := float64(atom)
+			atoms = append(atoms, jsonAtom{Num: &num})
+		case Constant:
+			name := string(atom)
+			atoms = append(atoms, jsonAtom{Const: &name})
+		case Variable:
+			name := string(atom)
+			atoms = append(atoms, jsonAtom{Var: &name})
+		default:
+			return nil, fmt.Errorf("can't marshal atom %v of type %T", atom, atom)
+		}
+	}
+
+	return json.Marshal(atoms)
+}
+
+// UnmarshalJSON decodes a stack encoded by MarshalJSON.
+func (s *Stack) UnmarshalJSON(data []byte) error {
+	var atoms []jsonAtom
+	if err := json.Unmarshal(data, &atoms); err != nil {
+		return err
+	}
+
+	items := make([]Atom, 0, len(atoms))
+
+	for i, atom := range atoms {
+		switch {
+		case atom.Op != nil:
+			op, err := parseAtom(*atom.Op)
+			if err != nil || !op.IsOperator() {
+				return fmt.Errorf("atom %d: unknown operator %q", i, *atom.Op)
+			}
+
+			items = append(items, op)
+		case atom.Num != nil:
+			items = append(items, Number(*atom.Num))
+		case atom.Const != nil:
+			constant, err := parseAtom(*atom.Const)
+			if _, ok := constant.(Constant); err != nil || !ok {
+				return fmt.Errorf("atom %d: unknown constant %q", i, *atom.Const)
+			}
+
+			items = append(items, constant)
+		case atom.Var != nil:
+			variable, err := parseAtom(*atom.Var)
+			if _, ok := variable.(Variable); err != nil || !ok {
+				return fmt.Errorf("atom %d: invalid variable name %q", i, *atom.Var)
+			}
+
+			items = append(items, variable)
+		default:
+			return fmt.Errorf("atom %d: expected one of \"op\", \"num\", \"const\" or \"var\"", i)
+		}
+	}
+
+	s.items = items
+
+	return nil
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
+	"os/signal"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode"
 )
 
 type Atom interface {
@@ -18,42 +29,388 @@ type Atom interface {
 type Operator string
 
 const (
-	ADD  Operator = "+"
-	DIV  Operator = "/"
-	MUL  Operator = "*"
-	SQRT Operator = "√"
+	ADD   Operator = "+"
+	SUB   Operator = "-"
+	DIV   Operator = "/"
+	MUL   Operator = "*"
+	POW   Operator = "^"
+	MOD   Operator = "%"
+	SQRT  Operator = "√"
+	NEG   Operator = "~"
+	LN    Operator = "ln"
+	EXP   Operator = "exp"
+	SIN   Operator = "sin"
+	COS   Operator = "cos"
+	TAN   Operator = "tan"
+	FACT  Operator = "!"
+	RECIP Operator = "⁻¹"
+	ROOT  Operator = "root"
 )
 
-func RandomOperator() Operator {
+// RandomOperators is the set of binary operators that RandomOperator chooses between. It can be changed to restrict or
+// extend the operators used in generated expressions, but must only contain operators that take two operands.
+var RandomOperators = []Operator{
+	ADD,
+	SUB,
+	DIV,
+	MUL,
+}
+
+// RandomOperator returns one of RandomOperators, chosen uniformly.
+func RandomOperator(r *rand.Rand) Operator {
+	return RandomOperators[r.Intn(len(RandomOperators))]
+}
+
+// GenerateOptions controls which operators and numbers generated expressions can contain. The zero value generates
+// whole numbers combined with RandomOperators and SQRT.
+type GenerateOptions struct {
+	// OperatorWeights biases generation towards some operators over others, such as favouring DIV and SQRT over MUL
+	// when approximating an irrational target. When it has positive weights for any binary operators, generated
+	// expressions choose between those binary operators in proportion to their weights instead of using
+	// RandomOperators, and likewise for unary operators. The weights don't change how often a unary operator is used
+	// rather than a binary one.
+	OperatorWeights map[Operator]float64
+
+	// Fractions allows generated expressions to contain numbers with one decimal place, such as 3.1, as well as whole
+	// numbers. Fractions make the search space much larger, but can find close approximations to some targets sooner.
+	Fractions bool
+
+	// Trig allows generated expressions to contain the trigonometric operators SIN, COS and TAN in addition to SQRT.
+	// It's off by default to keep the search space small.
+	Trig bool
+}
+
+// randomBinaryOperator returns a random binary operator for use in generated expressions, which is chosen from
+// OperatorWeights if it has positive weights for any binary operators and from RandomOperators otherwise.
+func (g GenerateOptions) randomBinaryOperator(r *rand.Rand) Operator {
+	if op, ok := weightedOperator(r, g.OperatorWeights, binaryOperator); ok {
+		return op
+	}
+
+	return RandomOperator(r)
+}
+
+// operators lists every operator in operatorTable, sorted to give weighted choices a fixed order to iterate over so
+// that they're reproducible.
+var operators = func() []Operator {
+	ops := make([]Operator, 0, len(operatorTable))
+	for op := range operatorTable {
+		ops = append(ops, op)
+	}
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}()
+
+// RandomOperatorWeighted returns one of the operators in weights, chosen with probability proportional to its weight.
+// Operators with a weight of zero or less are never chosen. It panics if no operator has a positive weight.
+func RandomOperatorWeighted(weights map[Operator]float64, r *rand.Rand) Operator {
+	op, ok := weightedOperator(r, weights, func(Operator) bool { return true })
+	if !ok {
+		panic("RandomOperatorWeighted: no operator has a positive weight")
+	}
+
+	return op
+}
+
+// weightedOperator chooses between the operators matching the predicate in proportion to their weights, returning
+// false if none of them have a positive weight.
+func weightedOperator(r *rand.Rand, weights map[Operator]float64, matches func(Operator) bool) (Operator, bool) {
+	total := 0.0
+
+	for _, op := range operators {
+		if weight := weights[op]; weight > 0 && matches(op) {
+			total += weight
+		}
+	}
+
+	if total == 0 {
+		return "", false
+	}
+
+	choice := r.Float64() * total
+	var chosen Operator
+
+	for _, op := range operators {
+		if weight := weights[op]; weight > 0 && matches(op) {
+			chosen = op
+			choice -= weight
+
+			if choice < 0 {
+				break
+			}
+		}
+	}
+
+	return chosen, true
+}
+
+// binaryOperator and unaryOperator return true if the operator takes two operands or one operand respectively.
+func binaryOperator(op Operator) bool { return op.Arity() == 2 }
+func unaryOperator(op Operator) bool  { return op.Arity() == 1 }
+
+// randomUnaryOperator returns a random unary operator for use in generated expressions, which is chosen from
+// OperatorWeights if it has positive weights for any unary operators.
+func (g GenerateOptions) randomUnaryOperator(r *rand.Rand) Operator {
+	if op, ok := weightedOperator(r, g.OperatorWeights, unaryOperator); ok {
+		return op
+	}
+
+	if !g.Trig {
+		return SQRT
+	}
+
 	return []Operator{
-		ADD,
-		DIV,
-		MUL,
-	}[rand.Intn(3)]
+		SQRT,
+		SIN,
+		COS,
+		TAN,
+	}[r.Intn(4)]
 }
 
 func (o Operator) IsOperator() bool {
 	return true
 }
 
+// operatorInfo describes how an operator behaves when evaluated.
+type operatorInfo struct {
+	// arity is the number of operands the operator takes.
+	arity int
+
+	// eval applies the operator to its operands, which are given in the order they were pushed, so for "2 3 ^" the
+	// arguments are [2, 3].
+	eval func(args []float64) (float64, error)
+}
+
+// errUndefined is returned by an operator when its result is undefined, which stops evaluation with a NaN result rather
+// than an error.
+var errUndefined = errors.New("undefined result")
+
+// operatorTable is the single source of truth for what each operator does. Parsing, validation, evaluation and weighted
+// generation are all driven by it, so supporting a new operator only needs an entry here, along with how it's rendered
+// if function notation isn't right.
+var operatorTable = map[Operator]operatorInfo{
+	ADD: {2, func(args []float64) (float64, error) { return args[0] + args[1], nil }},
+	SUB: {2, func(args []float64) (float64, error) { return args[0] - args[1], nil }},
+	MUL: {2, func(args []float64) (float64, error) { return args[0] * args[1], nil }},
+	DIV: {2, func(args []float64) (float64, error) {
+		if args[1] == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		return args[0] / args[1], nil
+	}},
+	POW: {2, func(args []float64) (float64, error) {
+		// The exponent is on top of the stack, so "2 3 ^" is 2^3.
+		result := math.Pow(args[0], args[1])
+		if math.IsNaN(result) {
+			return 0, errUndefined
+		}
+
+		return result, nil
+	}},
+	MOD: {2, func(args []float64) (float64, error) {
+		// Like DIV, the divisor is on top of the stack, so "7 3 %" is 7 mod 3. The result has the sign of the dividend.
+		if args[1] == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		return math.Mod(args[0], args[1]), nil
+	}},
+	ROOT: {2, func(args []float64) (float64, error) {
+		// The degree of the root is on top of the stack, so "27 3 root" is the cube root of 27.
+		y, x := args[0], args[1]
+		switch x {
+		case 0:
+			return 0, ErrDivisionByZero
+		case 2:
+			// Square and cube roots are more accurate than raising to the power 1/x, which can't be represented exactly.
+			if y < 0 {
+				return 0, errUndefined
+			}
+
+			return math.Sqrt(y), nil
+		case 3:
+			return math.Cbrt(y), nil
+		}
+
+		// Odd whole-number roots of negative numbers are real, but math.Pow doesn't know the exponent 1/x came from an
+		// odd root. Any other root of a negative number is undefined, just like a fractional power.
+		if y < 0 && x == math.Trunc(x) && math.Mod(x, 2) != 0 {
+			return -math.Pow(-y, 1/x), nil
+		}
+
+		result := math.Pow(y, 1/x)
+		if math.IsNaN(result) {
+			return 0, errUndefined
+		}
+
+		return result, nil
+	}},
+	SQRT: {1, func(args []float64) (float64, error) {
+		// Like an even root from ROOT, the square root of a negative number is undefined.
+		if args[0] < 0 {
+			return 0, errUndefined
+		}
+
+		return math.Sqrt(args[0]), nil
+	}},
+	NEG: {1, func(args []float64) (float64, error) { return -args[0], nil }},
+	LN: {1, func(args []float64) (float64, error) {
+		if args[0] <= 0 {
+			return 0, fmt.Errorf("%w: ln of %v", ErrDomain, Number(args[0]))
+		}
+
+		return math.Log(args[0]), nil
+	}},
+	EXP: {1, func(args []float64) (float64, error) { return math.Exp(args[0]), nil }},
+	SIN: {1, func(args []float64) (float64, error) { return math.Sin(args[0]), nil }},
+	COS: {1, func(args []float64) (float64, error) { return math.Cos(args[0]), nil }},
+	TAN: {1, func(args []float64) (float64, error) { return math.Tan(args[0]), nil }},
+	RECIP: {1, func(args []float64) (float64, error) {
+		if args[0] == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		return 1 / args[0], nil
+	}},
+	FACT: {1, func(args []float64) (float64, error) {
+		x := args[0]
+		if x < 0 || x != math.Trunc(x) {
+			return 0, fmt.Errorf("%w: factorial of %v", ErrDomain, Number(x))
+		}
+
+		// Multiplying out the factorial keeps it exact for as long as a float64 can represent it, stopping once it
+		// overflows to +Inf so that huge arguments don't take forever.
+		result := 1.0
+		for i := 2.0; i <= x && !math.IsInf(result, 1); i++ {
+			result *= i
+		}
+
+		return result, nil
+	}},
+}
+
+// Arity returns the number of operands the operator takes, or zero if it isn't a known operator.
+func (o Operator) Arity() int {
+	return operatorTable[o].arity
+}
+
+// Precedence returns how tightly the operator binds in infix notation, which is shared by ParseInfix and the infix
+// and LaTeX renderers. Higher precedences bind more tightly. Unary minus binds more loosely than exponentiation, so
+// "-2 ^ 2" is -(2 ^ 2), but function-style operators like √ bind tightest, so "√2 ^ 2" is (√2) ^ 2. Exponentiation
+// is the only right-associative operator.
+func (o Operator) Precedence() int {
+	switch o {
+	case ADD, SUB:
+		return 1
+	case MUL, DIV, MOD:
+		return 2
+	case NEG:
+		return 3
+	case POW:
+		return 4
+	default:
+		return 5
+	}
+}
+
 type Number float64
 
-func RandomWholeNumber(min, max int) Number {
-	return Number(float64(rand.Intn(max-min) + min))
+// RandomWholeNumber returns a random whole number in the range [min, max), or min itself if min and max are equal. Like
+// rand.Intn, it panics if max is less than min.
+func RandomWholeNumber(r *rand.Rand, min, max int) Number {
+	switch {
+	case max < min:
+		panic(fmt.Sprintf("RandomWholeNumber: max %d is less than min %d", max, min))
+	case max == min:
+		return Number(float64(min))
+	}
+
+	return Number(float64(r.Intn(max-min) + min))
+}
+
+// RandomNumber returns a random number in the range [min, max) rounded to one decimal place, or min itself if min and
+// max are equal. Like RandomWholeNumber, it panics if max is less than min.
+func RandomNumber(r *rand.Rand, min, max int) Number {
+	switch {
+	case max < min:
+		panic(fmt.Sprintf("RandomNumber: max %d is less than min %d", max, min))
+	case max == min:
+		return Number(float64(min))
+	}
+
+	// Working in tenths keeps the result exact to one decimal place, rather than rounding a float.
+	tenths := r.Intn(10*(max-min)) + 10*min
+
+	return Number(float64(tenths) / 10)
+}
+
+// randomNumber returns a random whole number in the range [min, max) for use in generated expressions. If Fractions is
+// set, it's occasionally a fraction instead, which is kept below max-1 so that it's no larger than the largest whole
+// number that could have been chosen.
+func (g GenerateOptions) randomNumber(r *rand.Rand, min, max int) Number {
+	if g.Fractions && r.Intn(4) == 0 {
+		return RandomNumber(r, min, max-1)
+	}
+
+	return RandomWholeNumber(r, min, max)
 }
 
 func (n Number) IsOperator() bool {
 	return false
 }
 
+// Constant is a named mathematical constant, such as π, which evaluates to a fixed value.
+type Constant string
+
+const (
+	PI Constant = "pi"
+	E  Constant = "e"
+)
+
+func (c Constant) IsOperator() bool {
+	return false
+}
+
+// Value returns the numeric value of the constant.
+func (c Constant) Value() Number {
+	switch c {
+	case PI:
+		return math.Pi
+	case E:
+		return math.E
+	default:
+		return Number(math.NaN())
+	}
+}
+
+// Variable is a named placeholder for a number, such as x, whose value is given when the expression is evaluated with
+// Apply.
+type Variable string
+
+func (v Variable) IsOperator() bool {
+	return false
+}
+
+// Stack is an expression in postfix notation. Like the built-in slices and maps, a Stack isn't safe for concurrent use:
+// it can be read from several goroutines at once, but must not be modified while anything else is using it. Methods
+// that return a changed expression, like MutateNumber, work on a copy and leave the original alone. Use Clone to give
+// each goroutine its own copy, or SyncStack to share one that's modified.
 type Stack struct {
+	// items holds the atoms from the top of the stack down, which is the order they appear in postfix notation.
 	items []Atom
+
+	// buf is the array items was last grown into by Push. While items is a suffix of buf, the slots before it are
+	// free, so Push can prepend an atom without copying the rest of the stack.
+	buf []Atom
 }
 
 func NewStack(items ...Atom) *Stack {
 	return &Stack{items: items}
 }
 
+// Pop removes and returns the top of the stack. It panics if the stack is empty; use PopOK if that's possible.
 func (s *Stack) Pop() Atom {
 	atom := s.items[0]
 	s.items = s.items[1:]
@@ -61,18 +418,59 @@ func (s *Stack) Pop() Atom {
 	return atom
 }
 
+// PopOK is like Pop, but returns false instead of panicking if the stack is empty.
+func (s *Stack) PopOK() (Atom, bool) {
+	if s.Len() == 0 {
+		return nil, false
+	}
+
+	return s.Pop(), true
+}
+
+// Len returns the total number of atoms in the stack, which is OperandCount plus OperatorCount.
 func (s *Stack) Len() int {
 	return len(s.items)
 }
 
+// Peek returns the top of the stack without removing it. It panics if the stack is empty; use PeekOK if that's
+// possible.
 func (s *Stack) Peek() Atom {
 	return s.items[0]
 }
 
+// PeekOK is like Peek, but returns false instead of panicking if the stack is empty.
+func (s *Stack) PeekOK() (Atom, bool) {
+	if s.Len() == 0 {
+		return nil, false
+	}
+
+	return s.Peek(), true
+}
+
+// Push adds an atom to the top of the stack in amortised constant time.
 func (s *Stack) Push(atom Atom) {
-	s.items = append([]Atom{atom}, s.items...)
+	free := len(s.buf) - len(s.items)
+
+	if free <= 0 || (len(s.items) > 0 && &s.buf[free] != &s.items[0]) {
+		s.grow()
+		free = len(s.buf) - len(s.items)
+	}
+
+	s.buf[free-1] = atom
+	s.items = s.buf[free-1:]
+}
+
+// grow moves the items to the end of a new, larger buf, leaving free space before them.
+func (s *Stack) grow() {
+	buf := make([]Atom, 2*len(s.items)+8)
+	copy(buf[len(buf)-len(s.items):], s.items)
+
+	s.buf = buf
+	s.items = buf[len(buf)-len(s.items):]
 }
 
+// Copy returns a new stack with the same atoms. The atoms themselves are shared rather than copied, which is safe
+// for the value types Number, Operator and Constant; use Clone if the copy must be fully independent.
 func (s *Stack) Copy() *Stack {
 	items := make([]Atom, s.Len())
 	copy(items, s.items)
@@ -80,44 +478,133 @@ func (s *Stack) Copy() *Stack {
 	return &Stack{items: items}
 }
 
-func (s *Stack) String() string {
-	var out []string
+// cloner is implemented by atoms that refer to mutable state, and so need copying themselves when a stack is cloned.
+type cloner interface {
+	Clone() Atom
+}
 
-	for _, atom := range s.items {
-		if atom.IsOperator() {
-			out = append(out, string(atom.(Operator)))
+// Clone returns a deep copy of the stack, which shares no state with the original, so that either can be modified
+// without affecting the other. Atoms that implement a Clone() Atom method are copied with it, and all others are
+// copied by value. For the atoms defined in this package, that makes it the same as Copy, but unlike Copy it stays
+// safe if an atom ever holds a pointer.
+func (s *Stack) Clone() *Stack {
+	items := make([]Atom, s.Len())
+
+	for i, atom := range s.items {
+		if c, ok := atom.(cloner); ok {
+			items[i] = c.Clone()
 		} else {
-			out = append(out, fmt.Sprint(atom.(Number)))
+			items[i] = atom
+		}
+	}
+
+	return &Stack{items: items}
+}
+
+// Atoms returns a copy of the atoms in the stack, from the top down. Modifying the returned slice doesn't affect the
+// stack.
+func (s *Stack) Atoms() []Atom {
+	atoms := make([]Atom, s.Len())
+	copy(atoms, s.items)
+
+	return atoms
+}
+
+// Each calls f for each atom in the stack from the top down, stopping early if f returns false.
+func (s *Stack) Each(f func(Atom) bool) {
+	for _, atom := range s.items {
+		if !f(atom) {
+			return
+		}
+	}
+}
+
+// equalTolerance is the relative tolerance used by Equal when comparing numbers.
+const equalTolerance = 1e-9
+
+// Equal returns true if both stacks contain the same atoms in the same order. Operators and constants must match
+// exactly, while numbers are equal if they differ by at most one part in a billion (or by 1e-9, for numbers smaller
+// than one), so that formatting differences like 3 and 3.0000000000000004 don't matter. NaN is equal to NaN, and
+// infinities are only equal to infinities with the same sign.
+func (s *Stack) Equal(other *Stack) bool {
+	if s.Len() != other.Len() {
+		return false
+	}
+
+	for i, atom := range s.items {
+		a, ok := atom.(Number)
+		if !ok {
+			if atom != other.items[i] {
+				return false
+			}
+
+			continue
+		}
+
+		b, ok := other.items[i].(Number)
+		if !ok {
+			return false
+		}
+
+		// The tolerance is meaningless for NaN and ±Inf, which are only equal to themselves.
+		if !finite(float64(a)) || !finite(float64(b)) {
+			if a != b && !(math.IsNaN(float64(a)) && math.IsNaN(float64(b))) {
+				return false
+			}
+
+			continue
+		}
+
+		scale := math.Max(1, math.Max(math.Abs(float64(a)), math.Abs(float64(b))))
+		if math.Abs(float64(a-b)) > equalTolerance*scale {
+			return false
 		}
 	}
 
-	return strings.Join(out, " ")
+	return true
+}
+
+// Reverse returns a new stack with the items in the opposite order, leaving the original unchanged.
+func (s *Stack) Reverse() *Stack {
+	items := make([]Atom, s.Len())
+
+	for i, atom := range s.items {
+		items[len(items)-i-1] = atom
+	}
+
+	return &Stack{items: items}
+}
+
+// String returns the expression in postfix notation, the same as Postfix. Use Infix or LaTeX for other notations.
+func (s *Stack) String() string {
+	return s.Postfix()
+}
+
+// Postfix returns the expression in postfix (reverse Polish) notation, with atoms separated by spaces, such as
+// "3 4 + 5 *". Parse reads this form back into a stack. Use Format with ASCIISqrt set to write square roots as "sqrt"
+// and reciprocals as "recip".
+func (s *Stack) Postfix() string {
+	return s.Format(FormatOptions{})
 }
 
-// Parse parses a string of space-separated operators and numbers in postfix notation to a stack.
+// Parse parses a string of whitespace-separated operators and numbers in postfix notation to a stack. Any amount of
+// whitespace is allowed between atoms, including leading and trailing whitespace.
+//
+// Numbers can be written as fractions, so "3/7" is parsed as the single number 3÷7 rather than a division. Since atoms
+// are separated by whitespace, "3 7 /" and "3/7" evaluate to the same value, but only the first contains a DIV
+// operator. A lone "/" is always the operator. Fractions are stored as their decimal value, so String doesn't print
+// them back as fractions.
+//
+// Any single letter other than e, which is Euler's number, is parsed as a Variable. Square roots and reciprocals can
+// also be written in ASCII as "sqrt" and "recip".
 func Parse(expression string) (*Stack, error) {
-	unparsedAtoms := strings.Split(expression, " ")
+	unparsedAtoms := strings.Fields(expression)
 	parsedAtoms := []Atom{}
 
 	for _, unparsedAtom := range unparsedAtoms {
-		var parsedAtom Atom
-
-		switch unparsedAtom {
-		case "+":
-			parsedAtom = ADD
-		case "*":
-			parsedAtom = MUL
-		case "/":
-			parsedAtom = DIV
-		case "√":
-			parsedAtom = SQRT
-		default:
-			num, err := strconv.ParseFloat(unparsedAtom, 64)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't parse %q: %w", unparsedAtom, err)
-			}
-
-			parsedAtom = Number(num)
+		parsedAtom, err := parseAtom(unparsedAtom)
+		if err != nil {
+			return nil, err
 		}
 
 		parsedAtoms = append(parsedAtoms, parsedAtom)
@@ -133,168 +620,871 @@ func Parse(expression string) (*Stack, error) {
 	return stack, nil
 }
 
+// ParseFile parses a list of expressions in postfix notation, one per line, as read by Parse. Blank lines and lines
+// starting with "#" are skipped, so the list can be annotated with comments. If any expression can't be parsed, the
+// error gives its line number.
+func ParseFile(r io.Reader) ([]*Stack, error) {
+	expressions := []*Stack{}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		expression, err := Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		expressions = append(expressions, expression)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return expressions, nil
+}
+
+// parseAtom parses a single operator, constant or number.
+func parseAtom(unparsedAtom string) (Atom, error) {
+	if op := Operator(unparsedAtom); op.Arity() > 0 {
+		return op, nil
+	}
+
+	switch unparsedAtom {
+	case "sqrt":
+		return SQRT, nil
+	case "recip":
+		return RECIP, nil
+	case "pi":
+		return PI, nil
+	case "e":
+		return E, nil
+	default:
+		// Any other single letter is a variable, although "e" is always Euler's number.
+		if len(unparsedAtom) == 1 && unicode.IsLetter(rune(unparsedAtom[0])) {
+			return Variable(unparsedAtom), nil
+		}
+
+		if i := strings.Index(unparsedAtom, "/"); i >= 0 {
+			return parseFraction(unparsedAtom, unparsedAtom[:i], unparsedAtom[i+1:])
+		}
+
+		num, err := strconv.ParseFloat(unparsedAtom, 64)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %q: %w", unparsedAtom, err)
+		}
+
+		return Number(num), nil
+	}
+}
+
+// parseFraction parses a number literal written as a fraction, such as "3/7", given the parts either side of the slash.
+func parseFraction(unparsedAtom, numerator, denominator string) (Atom, error) {
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse numerator of %q: %w", unparsedAtom, err)
+	}
+
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse denominator of %q: %w", unparsedAtom, err)
+	}
+
+	if d == 0 {
+		return nil, fmt.Errorf("couldn't parse %q: %w", unparsedAtom, ErrDivisionByZero)
+	}
+
+	return Number(n / d), nil
+}
+
+// valence returns the number of operands the atom consumes, which is its arity for operators and zero for anything
+// else.
+func valence(atom Atom) int {
+	if op, ok := atom.(Operator); ok {
+		return op.Arity()
+	}
+
+	return 0
+}
+
 // Valid returns true if the stack represents valid a RPN/infix expression.
-// Algorithm from: https://stackoverflow.com/questions/14506831/whats-the-fastest-way-to-check-if-input-string-is-a-correct-rpn-expression
 func (s *Stack) Valid() bool {
+	return s.Validate() == nil
+}
+
+// Validate returns an error describing why the stack isn't a valid RPN expression, or nil if it is.
+// Algorithm from: https://stackoverflow.com/questions/14506831/whats-the-fastest-way-to-check-if-input-string-is-a-correct-rpn-expression
+func (s *Stack) Validate() error {
 	size := 0
 
-	for _, atom := range s.items {
-		valence := 0
+	for i, atom := range s.items {
+		size += 1 - valence(atom)
 
-		switch atom {
-		case ADD:
-			valence = 2
-		case MUL:
-			valence = 2
-		case DIV:
-			valence = 2
-		case SQRT:
-			valence = 1
-		default:
-			valence = 0
+		if size <= 0 {
+			return fmt.Errorf("%w at atom %d", ErrStackUnderflow, i+1)
 		}
+	}
 
-		size += 1 - valence
+	switch {
+	case size == 0:
+		return errors.New("empty expression")
+	case size == 2:
+		return errors.New("1 leftover operand")
+	case size > 2:
+		return fmt.Errorf("%d leftover operands", size-1)
+	}
 
-		if size <= 0 {
-			return false
+	return nil
+}
+
+// Depth returns the largest number of operands that are on the stack at once while evaluating the expression, which
+// measures how wide its expression tree is. "1 2 3 + +" has a depth of 3, while the equivalent "1 2 + 3 +" only has a
+// depth of 2. It returns an error if the expression isn't valid.
+func (s *Stack) Depth() (int, error) {
+	if err := s.Validate(); err != nil {
+		return 0, err
+	}
+
+	size, depth := 0, 0
+
+	for _, atom := range s.items {
+		size += 1 - valence(atom)
+
+		if size > depth {
+			depth = size
 		}
 	}
 
-	return size == 1
+	return depth, nil
 }
 
+// ErrStackUnderflow is returned when an operator doesn't have enough operands to act on.
+var ErrStackUnderflow = errors.New("stack underflow")
+
+// ErrDivisionByZero is returned when an expression divides by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// ErrUnboundVariable is returned when an expression containing a variable is evaluated without a value for it.
+var ErrUnboundVariable = errors.New("unbound variable")
+
+// ErrDomain is returned when an operator is applied to a number outside of its domain, such as the log of a negative.
+var ErrDomain = errors.New("argument outside of domain")
+
 // Evaluate evaluates a stack of atoms in postfix notation.
-func Evaluate(s *Stack) float64 {
-	nums := &Stack{}
-	stack := s.Copy()
+// If any power or root is undefined (such as a negative base with a fractional exponent, or the square root or another
+// even root of a negative number), evaluation stops and NaN is returned, which can be detected with math.IsNaN.
+// Dividing by zero, taking a number modulo zero, taking the reciprocal of zero or taking a zeroth root returns
+// ErrDivisionByZero, and taking the natural log of a non-positive number or the factorial of anything other than a
+// non-negative whole number returns ErrDomain. Trigonometric operators treat their arguments as radians. Expressions
+// containing variables return ErrUnboundVariable; use Apply for those.
+func Evaluate(s *Stack) (float64, error) {
+	nums := getStack()
+	defer putStack(nums)
+
+	return evaluate(s, nums, math.Inf(1), nil)
+}
+
+// EvaluateInto is like Evaluate, but uses scratch to hold intermediate results instead of allocating a stack of its
+// own, which makes it cheaper to call in a tight loop. Anything already in scratch is discarded. It returns NaN if the
+// expression can't be evaluated, so errors can be detected with math.IsNaN but not told apart. The expression itself is
+// never modified.
+func EvaluateInto(s *Stack, scratch *Stack) float64 {
+	val, err := evaluate(s, scratch, math.Inf(1), nil)
+	if err != nil {
+		return math.NaN()
+	}
+
+	return val
+}
+
+// EvaluateAll evaluates each of the expressions, returning their values in the same order. Unlike calling Evaluate on
+// each of them, a single scratch stack is shared between them all. The value of any expression that can't be
+// evaluated, such as because it's invalid or divides by zero, is NaN.
+func EvaluateAll(stacks []*Stack) []float64 {
+	scratch := getStack()
+	defer putStack(scratch)
+
+	values := make([]float64, len(stacks))
+
+	for i, s := range stacks {
+		values[i] = EvaluateInto(s, scratch)
+	}
+
+	return values
+}
+
+// boundedHeadroom is how many times larger than the target an intermediate result in EvaluateBounded can be before
+// the expression is given up on.
+const boundedHeadroom = 1e6
+
+// errOutOfBounds is returned by evaluate when an intermediate result exceeds the limit it was given.
+var errOutOfBounds = errors.New("intermediate result out of bounds")
+
+// EvaluateBounded evaluates the expression, reporting whether its value is within tolerance of the target. To save
+// work on expressions that are nowhere near, it gives up early, returning NaN and false, as soon as an intermediate
+// result grows more than a million times larger than the target. This is a best-effort heuristic rather than exact
+// pruning: a later division could in principle bring such a value back into range, so a few expressions that would
+// have matched can be missed. It also returns NaN and false if the expression can't be evaluated, and the value and
+// false if it's evaluated but isn't within tolerance.
+func EvaluateBounded(s *Stack, target, tolerance float64) (float64, bool) {
+	nums := getStack()
+	defer putStack(nums)
+
+	limit := boundedHeadroom * math.Max(1, math.Abs(target)+tolerance)
+
+	val, err := evaluate(s, nums, limit, nil)
+	if err != nil {
+		return math.NaN(), false
+	}
+
+	return val, math.Abs(target-val) <= tolerance
+}
+
+// Step is the state of evaluation after processing a single atom of an expression.
+type Step struct {
+	// Atom is the atom that was processed.
+	Atom Atom
+
+	// Operands are the numbers on the operand stack afterwards, from the bottom to the top, so after "1 2" they are
+	// [1, 2].
+	Operands []float64
+}
+
+// EvaluateSteps evaluates the expression like Evaluate, but returns the state of the operand stack after each atom
+// rather than just the result, which helps to explain where a value comes from. If the expression can't be evaluated,
+// the steps up to the point where the error occurred are returned along with the error.
+func (s *Stack) EvaluateSteps() ([]Step, error) {
+	nums := getStack()
+	defer putStack(nums)
+
+	steps := make([]Step, 0, s.Len())
+
+	_, err := evaluateTraced(s, nums, math.Inf(1), nil, func(atom Atom, nums *Stack) {
+		operands := make([]float64, nums.Len())
+
+		// The top of the stack comes first in its items.
+		for i, operand := range nums.items {
+			operands[len(operands)-i-1] = float64(operand.(Number))
+		}
+
+		steps = append(steps, Step{Atom: atom, Operands: operands})
+	})
+
+	return steps, err
+}
 
-	for stack.Len() > 0 {
-		curr := stack.Pop()
+// Apply evaluates the expression like Evaluate, substituting the values given in bindings for its variables, so that
+// "x 2 *" is 6 with x bound to 3. It returns ErrUnboundVariable if a variable in the expression has no binding.
+func (s *Stack) Apply(bindings map[string]float64) (float64, error) {
+	nums := getStack()
+	defer putStack(nums)
 
+	return evaluate(s, nums, math.Inf(1), bindings)
+}
+
+// evaluate evaluates the expression, reading its atoms in place and pushing operands onto nums after emptying it. It
+// returns errOutOfBounds if the result of any operator has a magnitude greater than limit. Variables are looked up
+// in bindings, which can be nil if there aren't any.
+func evaluate(s *Stack, nums *Stack, limit float64, bindings map[string]float64) (float64, error) {
+	return evaluateTraced(s, nums, limit, bindings, nil)
+}
+
+// evaluateTraced is like evaluate, but calls trace with each atom and the operand stack after it's been processed, if
+// trace isn't nil.
+func evaluateTraced(s, nums *Stack, limit float64, bindings map[string]float64, trace func(Atom, *Stack)) (float64, error) {
+	nums.items = nums.items[len(nums.items):]
+
+	// argsBuf holds the operands of each operator, and is large enough for any operator's arity.
+	var argsBuf [2]float64
+
+	for _, curr := range s.items {
 		if curr.IsOperator() {
-			switch curr {
-			case ADD:
-				x := nums.Pop().(Number)
-				y := nums.Pop().(Number)
-				nums.Push(y + x)
-			case MUL:
-				x := nums.Pop().(Number)
-				y := nums.Pop().(Number)
-				nums.Push(y * x)
-			case DIV:
-				x := nums.Pop().(Number)
-				y := nums.Pop().(Number)
-				nums.Push(y / x)
-			case SQRT:
-				x := nums.Pop().(Number)
-				nums.Push(Number(math.Sqrt(float64(x))))
+			if nums.Len() < valence(curr) {
+				return 0, fmt.Errorf("%w at operator %s", ErrStackUnderflow, curr)
+			}
+
+			info, ok := operatorTable[curr.(Operator)]
+			if !ok {
+				return 0, fmt.Errorf("unknown operator %s", curr)
+			}
+
+			args := argsBuf[:info.arity]
+
+			// The top of the stack is the last operand.
+			for i := info.arity - 1; i >= 0; i-- {
+				args[i] = float64(nums.Pop().(Number))
+			}
+
+			result, err := info.eval(args)
+			if err == errUndefined {
+				if trace != nil {
+					nums.Push(Number(math.NaN()))
+					trace(curr, nums)
+				}
+
+				return math.NaN(), nil
+			} else if err != nil {
+				return 0, err
 			}
+
+			nums.Push(Number(result))
+
+			if math.Abs(float64(nums.Peek().(Number))) > limit {
+				return 0, errOutOfBounds
+			}
+		} else if constant, ok := curr.(Constant); ok {
+			nums.Push(constant.Value())
+		} else if variable, ok := curr.(Variable); ok {
+			value, ok := bindings[string(variable)]
+			if !ok {
+				return 0, fmt.Errorf("%w %s", ErrUnboundVariable, variable)
+			}
+
+			nums.Push(Number(value))
 		} else {
 			nums.Push(curr)
 		}
+
+		if trace != nil {
+			trace(curr, nums)
+		}
+	}
+
+	if nums.Len() != 1 {
+		return 0, fmt.Errorf("expected a single result, got %d operands", nums.Len())
 	}
 
-	return float64(nums.Peek().(Number))
+	return float64(nums.Peek().(Number)), nil
 }
 
-// Generate generates a random, valid RPN string of length n.
+// DefaultMinNum and DefaultMaxNum are the inclusive bounds on the numbers in expressions made by Generate.
+const (
+	DefaultMinNum = 1
+	DefaultMaxNum = 9
+)
+
+// Generate generates a random, valid RPN string of length n. The returned stack always has exactly n atoms.
 func Generate(length int) *Stack {
-	stack := NewStack(generateRecursive(1, 10, length)...)
+	return GenerateWithRand(globalRand, length)
+}
+
+// GenerateWithRand is like Generate, but draws from the given source of randomness instead of the global one. This
+// makes generation reproducible when r is seeded with a fixed value.
+func GenerateWithRand(r *rand.Rand, length int) *Stack {
+	return GenerateInRange(r, length, DefaultMinNum, DefaultMaxNum, GenerateOptions{})
+}
+
+// GenerateInRange is like GenerateWithRand, but the numbers in the expression are between minNum and maxNum inclusive,
+// and the operators and numbers are chosen according to opts. It panics if maxNum is less than minNum.
+func GenerateInRange(r *rand.Rand, length, minNum, maxNum int, opts GenerateOptions) *Stack {
+	stack := NewStack(generateRecursive(r, nil, minNum, maxNum+1, length, opts)...)
 
 	return stack
 }
 
-// generateRecursive
-func generateRecursive(min, max, length int) []Atom {
+// generateRecursive appends the atoms of a random, valid RPN expression containing exactly length atoms to dst,
+// returning the extended slice. Appending rather than returning a new slice lets callers reuse a buffer between
+// expressions. Numbers are drawn from [min, max), like randomNumber.
+func generateRecursive(r *rand.Rand, dst []Atom, min, max, length int, opts GenerateOptions) []Atom {
 	switch {
 	case length < 1:
-		return []Atom{}
+		return dst
 	case length == 1:
-		return []Atom{RandomWholeNumber(min, max)}
+		return append(dst, opts.randomNumber(r, min, max))
 	case length == 2:
-		return []Atom{RandomWholeNumber(min, max), SQRT}
+		return append(dst, opts.randomNumber(r, min, max), opts.randomUnaryOperator(r))
 	case length == 3:
-		return []Atom{RandomWholeNumber(min, max), RandomWholeNumber(min, max), RandomOperator()}
+		return append(dst, opts.randomNumber(r, min, max), opts.randomNumber(r, min, max), opts.randomBinaryOperator(r))
 	default:
-		if rand.Intn(4) == 0 {
-			return append(
-				generateRecursive(min, max, length-1),
-				SQRT,
-			)
+		if r.Intn(4) == 0 {
+			dst = generateRecursive(r, dst, min, max, length-1, opts)
+			return append(dst, opts.randomUnaryOperator(r))
 		} else {
-			return append(
-				generateRecursive(min, max, length/2),
-				append(
-					generateRecursive(min, max, length/2),
-					RandomOperator(),
-				)...,
-			)
+			// One atom is taken by the operator, and the rest are split as evenly as possible between its operands.
+			left := (length - 1) / 2
+			right := length - 1 - left
+
+			dst = generateRecursive(r, dst, min, max, left, opts)
+			dst = generateRecursive(r, dst, min, max, right, opts)
+			return append(dst, opts.randomBinaryOperator(r))
 		}
 	}
 }
 
-func Improve(expression *Stack, target, val, diff float64) (bool, float64, float64, *Stack) {
-	for i, atom := range expression.items {
-		if atom.IsOperator() {
+// Improve tries nudging each number in the expression up or down by step, returning the first change that brings the
+// expression closer to the target. If both directions help, the one that gets closer is kept. A step of 1 keeps whole
+// numbers whole, while smaller steps can be used to refine a near miss to more decimal places.
+//
+// The changes are made to a clone, so the expression passed in is never modified, even temporarily. If there is an
+// improvement, the changed clone is returned; otherwise the original expression is.
+func Improve(expression *Stack, target, val, diff, step float64) (bool, float64, float64, *Stack) {
+	candidate := expression.Clone()
+
+	for i, atom := range candidate.items {
+		num, ok := atom.(Number)
+		if !ok {
 			continue
 		}
 
-		num := atom.(Number)
-		expression.items[i] = num + 1
+		bestNum, bestVal, bestDiff := num, val, diff
+
+		for _, tweaked := range []Number{num + Number(step), num - Number(step)} {
+			candidate.items[i] = tweaked
 
-		newVal := Evaluate(expression)
-		newDiff := math.Abs(target - newVal)
+			newVal, err := Evaluate(candidate)
+			newDiff := math.Abs(target - newVal)
 
-		if newDiff < diff {
-			return true, newVal, newDiff, expression
+			if err == nil && finite(newVal) && newDiff < bestDiff {
+				bestNum, bestVal, bestDiff = tweaked, newVal, newDiff
+			}
 		}
 
-		expression.items[i] = num
+		candidate.items[i] = bestNum
+
+		if bestNum != num {
+			return true, bestVal, bestDiff, candidate
+		}
 	}
 
 	return false, val, diff, expression
 }
 
-// Search searches for approximations to the input number using basic math operations.
-// Precision is the number of decimal places.
-func Search(approximate float64, precision int, minLength, maxLength, minNum, maxNum int) {
-	epsilon := math.Pow10(-precision)
+// finite returns true if x is neither NaN nor ±Inf.
+func finite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// ImproveUntil repeatedly improves a copy of the expression until no single change to a number gets it any closer to
+// the target, or maxIter improvements have been made. It returns the improved expression and its distance from the
+// target, which is +Inf if the expression can't be evaluated or evaluates to NaN or ±Inf.
+//
+// Every accepted improvement strictly decreases the distance, so the same expression can never be revisited and tweaks
+// can't oscillate back and forth.
+func ImproveUntil(expr *Stack, target float64, maxIter int) (*Stack, float64) {
+	expression := expr.Clone()
+
+	val, err := Evaluate(expression)
+	if err != nil || !finite(val) {
+		return expression, math.Inf(1)
+	}
+
+	diff := math.Abs(target - val)
+
+	for i := 0; i < maxIter; i++ {
+		var improved bool
+
+		improved, val, diff, expression = Improve(expression, target, val, diff, 1)
+		if !improved {
+			break
+		}
+	}
+
+	return expression, diff
+}
+
+// GenerateDistribution writes count randomly generated expressions of the given length to w as CSV, along with their
+// values, to study the distribution of values the search draws from. Expressions that can't be evaluated are skipped.
+// The expressions are generated and evaluated by one worker per CPU while a single goroutine writes them, so the rows
+// aren't in any particular order.
+func GenerateDistribution(w io.Writer, length, count int) error {
+	workers := runtime.NumCPU()
+	rows := make(chan []string, 64*workers)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		// The count is shared out as evenly as possible, with the first count%workers workers taking one extra.
+		n := count / workers
+		if i < count%workers {
+			n++
+		}
+
+		wg.Add(1)
+
+		r := rand.New(rand.NewSource(rand.Int63()))
 
-	for i := 0; i < 10; i++ {
 		go func() {
-			for {
-				expression := Generate(rand.Intn(maxLength-minLength) + minLength)
-				val := Evaluate(expression)
-				diff := math.Abs(approximate - val)
+			defer wg.Done()
 
-				if diff < epsilon {
-					fmt.Printf("%f,%f,%s\n", diff/epsilon, val, expression.String())
+			scratch := &Stack{}
+
+			for j := 0; j < n; j++ {
+				expression := GenerateWithRand(r, length)
+
+				val, err := evaluate(expression, scratch, math.Inf(1), nil)
+				if err != nil {
+					continue
 				}
+
+				rows <- []string{fmt.Sprint(val), expression.String()}
 			}
 		}()
 	}
 
-	select {}
-}
+	go func() {
+		wg.Wait()
+		close(rows)
+	}()
 
-func generateDistribtuion() {
-	writer := csv.NewWriter(os.Stdout)
+	writer := csv.NewWriter(w)
 	writer.Write([]string{"num", "expression"})
 
-	for i := 0; i < 1_000_000; i++ {
-		expression := Generate(5)
-		val := Evaluate(expression)
-		writer.Write([]string{fmt.Sprint(val), expression.String()})
+	for row := range rows {
+		writer.Write(row)
 	}
 
 	writer.Flush()
+	return writer.Error()
 }
 
 func main() {
-	Search(math.Pi, 5, 10, 20, 1, 100)
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verify(os.Args[2:])
+		return
+	}
+
+	target := flag.String("target", "pi", "comma-separated numbers to approximate, as decimals or names of constants such as pi or phi")
+	precision := flag.Int("precision", 5, "number of decimal places to match the target to")
+	relative := flag.Bool("relative", false, "count precision in significant figures rather than decimal places")
+	epsilon := flag.Float64("epsilon", 0, "allowed distance from the target, overriding -precision if non-zero")
+	minLength := flag.Int("min-length", 10, "minimum number of atoms in an expression")
+	maxLength := flag.Int("max-length", 20, "maximum number of atoms in an expression")
+	minNum := flag.Int("min-num", 1, "smallest number to use in expressions")
+	maxNum := flag.Int("max-num", 100, "largest number to use in expressions")
+	workers := flag.Int("workers", 0, "number of search goroutines, defaulting to the number of CPUs")
+	format := flag.String("format", "csv", "output format, either csv or ndjson")
+	weights := flag.String("weights", "", "comma-separated operator weights for generation, such as \"/=2,sqrt=3,*=0.5\"")
+	fractions := flag.Bool("fractions", false, "allow numbers with one decimal place, such as 3.1, in expressions")
+	maxMagnitude := flag.Float64("max-magnitude", DefaultMaxMagnitude, "largest intermediate value to allow while evaluating")
+	maxOperators := flag.String("max-operators", "", "comma-separated limits on how often operators can appear, such as \"sqrt=2,/=1\"")
+	seeds := flag.String("seeds", "", "comma-separated postfix expressions for some workers to improve, such as \"22 7 /,355 113 /\"")
+	avoidTrivial := flag.Bool("avoid-trivial", false, "skip expressions containing operations like x * 1 or x + 0")
+	maxPerProfile := flag.Int("max-per-profile", 0, "maximum number of results with the same operators in the same places (0 means no limit)")
+	timeout := flag.Duration("timeout", 0, "stop searching after this long and print the best results, or 0 to search forever")
+	progress := flag.Duration("progress", 0, "how often to print search progress to stderr, such as 5s, or 0 to disable")
+	strategy := flag.String("strategy", "random", "how to search: random, anneal, ga or exhaustive, or list to describe them")
+	flag.Parse()
+
+	if *strategy == "list" {
+		for _, s := range Strategies {
+			fmt.Printf("%-12s%s\n", s.Strategy, s.Description)
+		}
+
+		return
+	}
+
+	searchStrategy, err := ParseStrategy(*strategy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	targets, err := parseTargets(*target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	outputFormat, err := ParseOutputFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	operatorWeights, err := parseWeights(*weights)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	limits, err := parseLimits(*maxOperators)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	seedExpressions, err := parseSeeds(*seeds)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opts := SearchOptions{
+		Precision:     *precision,
+		Relative:      *relative,
+		Epsilon:       *epsilon,
+		MinLength:     *minLength,
+		MaxLength:     *maxLength,
+		MinNum:        *minNum,
+		MaxNum:        *maxNum,
+		Workers:       *workers,
+		MaxMagnitude:  *maxMagnitude,
+		MaxOperators:  limits,
+		Seeds:         seedExpressions,
+		AvoidTrivial:  *avoidTrivial,
+		MaxPerProfile: *maxPerProfile,
+		Strategy:      searchStrategy,
+		GenerateOptions: GenerateOptions{
+			OperatorWeights: operatorWeights,
+			Fractions:       *fractions,
+		},
+	}
+
+	// Interrupting the search stops it cleanly, so that buffered output is flushed and the best results are printed. Once
+	// it has been interrupted, the default behaviour is restored so that a second interrupt kills it straight away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The goroutine is given the signal context, since ctx itself is replaced below if there's a timeout.
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		stop()
+	}(ctx)
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	searcher, err := SearchTargets(ctx, targets, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *progress > 0 {
+		go reportProgress(searcher, *progress)
+	}
+
+	writer := NewResultWriter(os.Stdout, outputFormat, opts)
+
+	for result := range searcher.Results() {
+		if err := writer.Write(result); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// Results can be a long time apart, so they're flushed as they arrive rather than buffered.
+		if err := writer.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if ctx.Err() != nil {
+		printBest(searcher, targets)
+	}
+}
+
+// printBest prints the closest expression found to each target to stderr, once a search has stopped.
+func printBest(searcher *Searcher, targets []float64) {
+	for i, best := range searcher.BestPerTarget() {
+		if best.Expression == nil {
+			fmt.Fprintf(os.Stderr, "no expressions were evaluated for %v\n", targets[i])
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "best for %v: %s\n", best.Target, best)
+	}
+}
+
+// reportProgress prints a status line to stderr at every interval, until the program exits.
+func reportProgress(searcher *Searcher, interval time.Duration) {
+	for range time.Tick(interval) {
+		stats := searcher.Stats()
+
+		fmt.Fprintf(
+			os.Stderr,
+			"%s: evaluated %d expressions (%.0f/s), found %d matches\n",
+			stats.Elapsed.Round(time.Second), stats.Evaluated, stats.Rate, stats.Matches,
+		)
+	}
+}
+
+// namedConstants are the constants that can be searched for by name.
+var namedConstants = map[string]float64{
+	"pi":      math.Pi,
+	"tau":     2 * math.Pi,
+	"e":       math.E,
+	"phi":     math.Phi,
+	"sqrt2":   math.Sqrt2,
+	"sqrte":   math.SqrtE,
+	"sqrtpi":  math.SqrtPi,
+	"sqrtphi": math.SqrtPhi,
+	"ln2":     math.Ln2,
+	"ln10":    math.Ln10,
+	"log2e":   math.Log2E,
+	"log10e":  math.Log10E,
 }
 
+// lookupConstant returns the value of a well-known constant, such as "pi" or "phi".
+func lookupConstant(name string) (float64, bool) {
+	value, ok := namedConstants[name]
+	return value, ok
+}
+
+// parseWeights parses a comma-separated list of operator weights, such as "/=2,sqrt=3", returning nil if there are
+// none.
+func parseWeights(weights string) (map[Operator]float64, error) {
+	values, err := parseOperatorValues(weights)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	parsed := make(map[Operator]float64)
+
+	for op, value := range values {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight for %s: %w", op, err)
+		}
+
+		parsed[op] = weight
+	}
+
+	return parsed, nil
+}
+
+// parseLimits parses a comma-separated list of limits on how often operators can appear, such as "sqrt=2,/=1",
+// returning nil if there are none.
+func parseLimits(limits string) (map[Operator]int, error) {
+	values, err := parseOperatorValues(limits)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	parsed := make(map[Operator]int)
+
+	for op, value := range values {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit for %s: %w", op, err)
+		}
+
+		parsed[op] = limit
+	}
+
+	return parsed, nil
+}
+
+// parseOperatorValues parses a comma-separated list of operator=value pairs, returning the unparsed value for each
+// operator, or nil if the list is empty.
+func parseOperatorValues(list string) (map[Operator]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	values := make(map[Operator]string)
+
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid pair %q: expected operator=value", pair)
+		}
+
+		atom, err := parseAtom(strings.TrimSpace(parts[0]))
+		if err != nil || !atom.IsOperator() {
+			return nil, fmt.Errorf("invalid pair %q: unknown operator %q", pair, parts[0])
+		}
+
+		values[atom.(Operator)] = strings.TrimSpace(parts[1])
+	}
+
+	return values, nil
+}
+
+// parseSeeds parses a comma-separated list of postfix expressions, returning nil if there are none.
+func parseSeeds(seeds string) ([]*Stack, error) {
+	if seeds == "" {
+		return nil, nil
+	}
+
+	parsed := []*Stack{}
+
+	for _, seed := range strings.Split(seeds, ",") {
+		expression, err := Parse(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q: %w", seed, err)
+		}
+
+		parsed = append(parsed, expression)
+	}
+
+	return parsed, nil
+}
+
+// parseTargets parses a comma-separated list of search targets, such as "pi,e,phi".
+func parseTargets(targets string) ([]float64, error) {
+	values := []float64{}
+
+	for _, target := range strings.Split(targets, ",") {
+		value, err := parseTarget(strings.TrimSpace(target))
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// parseTarget parses a search target, which is either a decimal number or the name of a constant.
+func parseTarget(target string) (float64, error) {
+	if value, ok := lookupConstant(target); ok {
+		return value, nil
+	}
+
+	value, err := strconv.ParseFloat(target, 64)
+	if err != nil {
+		names := make([]string, 0, len(namedConstants))
+		for name := range namedConstants {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return 0, fmt.Errorf("invalid target %q: expected a number or one of %s", target, strings.Join(names, ", "))
+	}
+
+	return value, nil
+}
+
+// globalSource is a rand.Source backed by the top-level math/rand functions, which are safe for concurrent use.
+type globalSource struct{}
+
+func (globalSource) Int63() int64 {
+	return rand.Int63()
+}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+func (globalSource) Seed(seed int64) {
+	rand.Seed(seed)
+}
+
+// Seed seeds the global source of randomness used by Generate and to seed each Search worker, making them
+// reproducible. Each worker's expressions are then the same from run to run, although with more than one worker the
+// order in which their results arrive can still vary.
+func Seed(seed int64) {
+	rand.Seed(seed)
+}
+
+// globalRand wraps the global math/rand source for use by functions that take a *rand.Rand.
+var globalRand = rand.New(globalSource{})
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestEvaluateDivisionByZero(t *testing.T) {
+	for _, expression := range []string{"1 0 /", "0 0 /", "3 4 4 - /"} {
+		s, err := Parse(expression)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expression, err)
+		}
+
+		if _, err := Evaluate(s); !errors.Is(err, ErrDivisionByZero) {
+			t.Errorf("Evaluate(%q): got error %v, want %v", expression, err, ErrDivisionByZero)
+		}
+	}
+}
+
+func TestRandomWholeNumber(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		if n := RandomWholeNumber(r, 7, 7); n != 7 {
+			t.Fatalf("RandomWholeNumber(r, 7, 7) = %v, want 7", n)
+		}
+
+		if n := RandomWholeNumber(r, 3, 5); n != 3 && n != 4 {
+			t.Fatalf("RandomWholeNumber(r, 3, 5) = %v, want 3 or 4", n)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RandomWholeNumber(r, 5, 3) didn't panic")
+		}
+	}()
+
+	RandomWholeNumber(r, 5, 3)
+}
+
+func TestImproveUntilNonFinite(t *testing.T) {
+	for _, expression := range []string{"2 3 - √", "0 1 - √ 2 +"} {
+		s, err := Parse(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, diff := ImproveUntil(s, 1, 10); !math.IsInf(diff, 1) {
+			t.Errorf("ImproveUntil(%q, 1, 10): got distance %v, want +Inf", expression, diff)
+		}
+	}
+}
+
+// benchmarkLengths are the expression lengths benchmarked by BenchmarkEvaluate and BenchmarkParse.
+var benchmarkLengths = []int{5, 10, 20, 40}
+
+// benchmarkExpressions returns n expressions of the given length, generated from a fixed seed so that every run of a
+// benchmark works on the same expressions.
+func benchmarkExpressions(n, length int) []*Stack {
+	r := rand.New(rand.NewSource(1))
+	expressions := make([]*Stack, n)
+
+	for i := range expressions {
+		expressions[i] = GenerateWithRand(r, length)
+	}
+
+	return expressions
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	for _, length := range benchmarkLengths {
+		expressions := benchmarkExpressions(100, length)
+
+		b.Run(fmt.Sprintf("length=%d", length), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				Evaluate(expressions[i%len(expressions)])
+			}
+		})
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		GenerateWithRand(r, 15)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, length := range benchmarkLengths {
+		expressions := benchmarkExpressions(100, length)
+
+		inputs := make([]string, len(expressions))
+		for i, expression := range expressions {
+			inputs[i] = expression.String()
+		}
+
+		b.Run(fmt.Sprintf("length=%d", length), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(inputs[i%len(inputs)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPush pushes n atoms onto an empty stack per iteration. Pushing is amortised constant time, so the time per
+// atom should stay about the same as n grows.
+func BenchmarkPush(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("atoms=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				s := &Stack{}
+				for j := 0; j < n; j++ {
+					s.Push(ADD)
+				}
+			}
+		})
+	}
+}
+
+// quickConfig returns the configuration for property tests, with a fixed seed so that failures are reproducible.
+func quickConfig() *quick.Config {
+	return &quick.Config{MaxCount: 1000, Rand: rand.New(rand.NewSource(1))}
+}
+
+// quickExpression is a random valid expression, generated for property tests.
+type quickExpression struct {
+	*Stack
+}
+
+func (quickExpression) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(quickExpression{GenerateWithRand(r, r.Intn(10)+1)})
+}
+
+// sameResult returns true if both evaluations failed, or both succeeded with the same value, counting NaN as equal to
+// itself.
+func sameResult(a float64, errA error, b float64, errB error) bool {
+	if errA != nil || errB != nil {
+		return errA != nil && errB != nil
+	}
+
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
+
+func TestStringRoundTripsThroughParse(t *testing.T) {
+	roundTrip := func(s *Stack) bool {
+		parsed, err := Parse(s.String())
+		if err != nil {
+			return false
+		}
+
+		want, wantErr := Evaluate(s)
+		got, gotErr := Evaluate(parsed)
+
+		return sameResult(got, gotErr, want, wantErr)
+	}
+
+	generated := func(a quickExpression) bool { return roundTrip(a.Stack) }
+	if err := quick.Check(generated, quickConfig()); err != nil {
+		t.Error(err)
+	}
+
+	// Arbitrary floats can need up to 17 significant digits to round-trip, unlike the whole numbers in generated
+	// expressions.
+	arbitrary := func(x, y float64) bool { return roundTrip(NewStack(Number(x), Number(y), SUB)) }
+	if err := quick.Check(arbitrary, quickConfig()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImproveLeavesInputUnchanged(t *testing.T) {
+	tests := []struct {
+		expression string
+		target     float64
+		improved   bool
+	}{
+		{"3 1 +", 4, false},
+		{"3 1 +", 5, true},
+		{"22 7 /", math.Pi, false},
+		{"2 √ 3 *", 1, true},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		original := s.Clone()
+		val, _ := Evaluate(s)
+
+		improved, _, _, result := Improve(s, test.target, val, math.Abs(test.target-val), 1)
+		if improved != test.improved {
+			t.Errorf("Improve(%q, %v): got improved %v, want %v", test.expression, test.target, improved, test.improved)
+		}
+
+		if !s.Equal(original) {
+			t.Errorf("Improve(%q, %v) changed its input to %q", test.expression, test.target, s)
+		}
+
+		if !improved && result != s {
+			t.Errorf("Improve(%q, %v) didn't return its input when there was no improvement", test.expression, test.target)
+		}
+	}
+}
+
+func TestFactorial(t *testing.T) {
+	values := []struct {
+		expression string
+		want       float64
+	}{
+		{"0 !", 1},
+		{"1 !", 1},
+		{"5 !", 120},
+		{"20 !", 2432902008176640000},
+		{"3 ! !", 720},
+	}
+
+	for _, test := range values {
+		s, err := Parse(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := Evaluate(s); err != nil || got != test.want {
+			t.Errorf("Evaluate(%q) = %v, %v, want %v", test.expression, got, err, test.want)
+		}
+	}
+
+	for _, x := range []float64{-1, -0.5, 2.5, math.NaN()} {
+		s := NewStack(Number(x), FACT)
+
+		if _, err := Evaluate(s); !errors.Is(err, ErrDomain) {
+			t.Errorf("Evaluate(%q): got error %v, want %v", s, err, ErrDomain)
+		}
+	}
+}
+
+func TestStringIsPostfix(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	opts := GenerateOptions{Fractions: true, Trig: true}
+
+	for i := 0; i < 100; i++ {
+		s := GenerateInRange(r, r.Intn(20)+1, 1, 9, opts)
+
+		if s.String() != s.Postfix() {
+			t.Fatalf("String() = %q, but Postfix() = %q", s.String(), s.Postfix())
+		}
+
+		ascii := s.Format(FormatOptions{ASCIISqrt: true})
+		if strings.Contains(ascii, string(SQRT)) {
+			t.Fatalf("Format with ASCIISqrt wrote %q", ascii)
+		}
+
+		if parsed, err := Parse(ascii); err != nil || !parsed.Equal(s) {
+			t.Fatalf("Parse(%q) = %q, %v, want %q", ascii, parsed, err, s)
+		}
+	}
+}
+
+func TestReciprocal(t *testing.T) {
+	for _, expression := range []string{"4 ⁻¹", "4 recip"} {
+		s, err := Parse(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := s.String(); got != "4 ⁻¹" {
+			t.Errorf("Parse(%q).String() = %q, want %q", expression, got, "4 ⁻¹")
+		}
+
+		if got := s.Format(FormatOptions{ASCIISqrt: true}); got != "4 recip" {
+			t.Errorf("Parse(%q).Format with ASCIISqrt = %q, want %q", expression, got, "4 recip")
+		}
+
+		if val, err := Evaluate(s); err != nil || val != 0.25 {
+			t.Errorf("Evaluate(%q) = %v, %v, want 0.25", expression, val, err)
+		}
+	}
+
+	if _, err := Evaluate(NewStack(Number(0), RECIP)); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Evaluate(0 ⁻¹) returned %v, want ErrDivisionByZero", err)
+	}
+}
+
+// junkPieces are the fragments that TestParseJunk builds its inputs from, mixing atoms Parse accepts with ones it
+// doesn't.
+var junkPieces = []string{
+	"0", "1", "-3", "2.5", "1e308", "-1e-320", "3/7", "1/0", "0/0", "NaN", "Inf", ".", "-", "/", "//",
+	"+", "*", "^", "%", "√", "sqrt", "~", "!", "recip", "⁻¹", "⁻", "root", "ln", "exp", "sin", "pi", "e", "x", "xy",
+	"(", ")", "\x00", "é", "\t", "\n",
+}
+
+func TestParseJunk(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		var b strings.Builder
+
+		for j := r.Intn(10); j > 0; j-- {
+			if r.Intn(10) == 0 {
+				b.WriteByte(byte(r.Intn(256)))
+			} else {
+				b.WriteString(junkPieces[r.Intn(len(junkPieces))])
+			}
+
+			if r.Intn(4) > 0 {
+				b.WriteByte(' ')
+			}
+		}
+
+		input := b.String()
+
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Fatalf("Parse(%q) panicked: %v", input, p)
+				}
+			}()
+
+			if s, err := Parse(input); err == nil && s.Valid() {
+				Evaluate(s)
+			}
+		}()
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, opts := range []GenerateOptions{{}, {Fractions: true, Trig: true}} {
+		for i := 0; i < 1000; i++ {
+			s := GenerateInRange(r, r.Intn(20)+1, -5, 100, opts)
+
+			parsed, err := Parse(s.String())
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", s, err)
+			}
+
+			if !parsed.Equal(s) {
+				t.Fatalf("Parse(%q) = %q", s, parsed)
+			}
+		}
+	}
+
+	nonFinite := NewStack(Number(math.Inf(1)), Number(math.Inf(-1)), Number(math.NaN()), ADD, ADD)
+
+	parsed, err := Parse(nonFinite.String())
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", nonFinite, err)
+	}
+
+	if !parsed.Equal(nonFinite) {
+		t.Errorf("Parse(%q) = %q", nonFinite, parsed)
+	}
+}
+
+func TestStackEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"3 4 +", "3 4 +", true},
+		{"3", "3.0000000000000004", true},
+		{"3", "3.1", false},
+		{"0", "1e-12", true},
+		{"3 4 +", "3 4 *", false},
+		{"3 4 +", "3 4 + 5", false},
+		{"pi", "3.141592653589793", false},
+		{"inf", "inf", true},
+		{"-inf", "-inf", true},
+		{"inf", "-inf", false},
+		{"inf", "3", false},
+		{"3", "inf", false},
+		{"inf", "1e308", false},
+		{"NaN", "NaN", true},
+		{"NaN", "3", false},
+		{"3", "NaN", false},
+		{"NaN", "inf", false},
+	}
+
+	for _, test := range tests {
+		a, err := Parse(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := Parse(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := a.Equal(b); got != test.want {
+			t.Errorf("Parse(%q).Equal(Parse(%q)) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// quickNumber is a random number for property tests, between -1e10 and 1e10 so that multiplying two of them can't
+// overflow.
+type quickNumber float64
+
+func (quickNumber) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(quickNumber((2*r.Float64() - 1) * math.Pow10(r.Intn(21)-10)))
+}
+
+// combine returns the expression that applies the binary operator to a and b.
+func combine(a, b *Stack, op Operator) *Stack {
+	return NewStack(append(append(a.Atoms(), b.Atoms()...), op)...)
+}
+
+func TestCommutativity(t *testing.T) {
+	for _, op := range []Operator{ADD, MUL} {
+		commutes := func(a, b quickExpression) bool {
+			x, errX := Evaluate(combine(a.Stack, b.Stack, op))
+			y, errY := Evaluate(combine(b.Stack, a.Stack, op))
+
+			return sameResult(x, errX, y, errY)
+		}
+
+		if err := quick.Check(commutes, quickConfig()); err != nil {
+			t.Errorf("%s doesn't commute: %v", op, err)
+		}
+	}
+}
+
+func TestSqrtOfSquare(t *testing.T) {
+	sqrtOfSquare := func(x quickNumber) bool {
+		val, err := Evaluate(NewStack(Number(x), Number(x), MUL, SQRT))
+		return err == nil && val == math.Abs(float64(x))
+	}
+
+	if err := quick.Check(sqrtOfSquare, quickConfig()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDivideThenMultiply(t *testing.T) {
+	inverse := func(x, y quickNumber) bool {
+		if y == 0 {
+			return true
+		}
+
+		val, err := Evaluate(NewStack(Number(x), Number(y), DIV, Number(y), MUL))
+		return err == nil && math.Abs(val-float64(x)) <= 1e-15*math.Abs(float64(x))
+	}
+
+	if err := quick.Check(inverse, quickConfig()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "fmt"
+
+// MutateNumber returns a copy of the stack with delta added to the number at index i. It returns an error if there
+// isn't a number at that index.
+func (s *Stack) MutateNumber(i int, delta float64) (*Stack, error) {
+	if i < 0 || i >= s.Len() {
+		return nil, fmt.Errorf("index %d out of range for stack of length %d", i, s.Len())
+	}
+
+	num, ok := s.items[i].(Number)
+	if !ok {
+		return nil, fmt.Errorf("atom %d is %v, not a number", i, s.items[i])
+	}
+
+	items := s.Atoms()
+	items[i] = num + Number(delta)
+
+	return NewStack(items...), nil
+}
+
+// MapNumbers returns a copy of the stack with every number replaced by the result of calling f on it, leaving
+// operators, constants and variables as they are. For example, s.MapNumbers(func(n Number) Number { return n * 2 })
+// doubles every number in the expression.
+func (s *Stack) MapNumbers(f func(Number) Number) *Stack {
+	mapped := s.Clone()
+
+	for i, atom := range mapped.items {
+		if num, ok := atom.(Number); ok {
+			mapped.items[i] = f(num)
+		}
+	}
+
+	return mapped
+}
+
+// SwapOperator returns a copy of the stack with the operator at index i replaced by op. It returns an error if there
+// isn't an operator at that index, or if op takes a different number of operands so the result would be invalid.
+func (s *Stack) SwapOperator(i int, op Operator) (*Stack, error) {
+	if i < 0 || i >= s.Len() {
+		return nil, fmt.Errorf("index %d out of range for stack of length %d", i, s.Len())
+	}
+
+	if !s.items[i].IsOperator() {
+		return nil, fmt.Errorf("atom %d is %v, not an operator", i, s.items[i])
+	}
+
+	items := s.Atoms()
+	items[i] = op
+	mutated := NewStack(items...)
+
+	if s.Valid() && !mutated.Valid() {
+		return nil, fmt.Errorf("replacing %s with %s at atom %d makes the expression invalid", s.items[i], op, i)
+	}
+
+	return mutated, nil
+}
+
+// ReplaceSubtree returns a copy of the stack with the subexpression ending at index i replaced by the replacement
+// expression. In postfix notation the last atom of a subexpression is its outermost operator, so for "3 4 + 5 *",
+// index 2 refers to "3 4 +". It returns an error if the replacement or the result is invalid.
+func (s *Stack) ReplaceSubtree(i int, replacement *Stack) (*Stack, error) {
+	if i < 0 || i >= s.Len() {
+		return nil, fmt.Errorf("index %d out of range for stack of length %d", i, s.Len())
+	}
+
+	if err := replacement.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid replacement: %w", err)
+	}
+
+	start := subtreeStart(s.items, i)
+	if start < 0 {
+		return nil, fmt.Errorf("no complete subexpression ends at atom %d", i)
+	}
+
+	items := make([]Atom, 0, s.Len()-(i+1-start)+replacement.Len())
+	items = append(items, s.items[:start]...)
+	items = append(items, replacement.items...)
+	items = append(items, s.items[i+1:]...)
+	replaced := NewStack(items...)
+
+	if s.Valid() && !replaced.Valid() {
+		return nil, fmt.Errorf("replacing the subexpression ending at atom %d makes the expression invalid", i)
+	}
+
+	return replaced, nil
+}
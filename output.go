@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// OutputFormat is a format search results can be written in.
+type OutputFormat int
+
+const (
+	// CSV writes a header row followed by one "ratio,target,value,digits,expression,complexity" row per result, where
+	// ratio is the distance from the target as a fraction of the allowed error and digits is the number of leading
+	// digits of the value that match the target.
+	CSV OutputFormat = iota
+
+	// NDJSON writes one {"target":...,"diff":...,"value":...,"digits":...,"expr":"...","complexity":...} object per
+	// line.
+	NDJSON
+)
+
+// ParseOutputFormat parses the name of an output format, either "csv" or "ndjson".
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch name {
+	case "csv":
+		return CSV, nil
+	case "ndjson":
+		return NDJSON, nil
+	default:
+		return 0, fmt.Errorf("unknown output format %q: expected csv or ndjson", name)
+	}
+}
+
+// MatchedDigits returns the number of leading digits of the value that match the target, counting the integer part as
+// well as the decimal places.
+func (r Result) MatchedDigits() int {
+	return matchedDigits(r.Target, r.Value)
+}
+
+// String returns a human-readable description of the result, such as "3 √ = 1.7320508075688772 (diff 1e-05, 5
+// matching digits)".
+func (r Result) String() string {
+	return fmt.Sprintf("%s = %v (diff %g, %d matching digits)", r.Expression, r.Value, r.Diff, r.MatchedDigits())
+}
+
+// csvHeader names the fields returned by CSVRecord.
+var csvHeader = []string{"target", "value", "digits", "expression", "complexity"}
+
+// CSVRecord returns the result as a "target,value,digits,expression,complexity" CSV record.
+func (r Result) CSVRecord() []string {
+	return []string{
+		strconv.FormatFloat(r.Target, 'g', -1, 64),
+		strconv.FormatFloat(r.Value, 'g', -1, 64),
+		strconv.Itoa(r.MatchedDigits()),
+		r.Expression.String(),
+		strconv.Itoa(r.Expression.Complexity()),
+	}
+}
+
+// MarshalJSON encodes the result as a {"target":...,"diff":...,"value":...,"digits":...,"expr":"...","complexity":...}
+// object.
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Target     float64 `json:"target"`
+		Diff       float64 `json:"diff"`
+		Value      float64 `json:"value"`
+		Digits     int     `json:"digits"`
+		Expr       string  `json:"expr"`
+		Complexity int     `json:"complexity"`
+	}{
+		r.Target,
+		r.Diff,
+		r.Value,
+		r.MatchedDigits(),
+		r.Expression.String(),
+		r.Expression.Complexity(),
+	})
+}
+
+// ResultWriter writes search results in a particular format.
+type ResultWriter interface {
+	Write(result Result) error
+	Flush() error
+}
+
+// NewResultWriter returns a ResultWriter that writes to w in the given format. The options are those the results were
+// searched for with, which some formats use to scale the distance from the target by the allowed error.
+func NewResultWriter(w io.Writer, format OutputFormat, opts SearchOptions) ResultWriter {
+	switch format {
+	case NDJSON:
+		return &ndjsonResultWriter{encoder: json.NewEncoder(w)}
+	default:
+		return &csvResultWriter{writer: csv.NewWriter(w), opts: opts}
+	}
+}
+
+type csvResultWriter struct {
+	writer        *csv.Writer
+	opts          SearchOptions
+	headerWritten bool
+}
+
+func (c *csvResultWriter) Write(result Result) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	ratio := strconv.FormatFloat(result.Diff/c.opts.epsilon(result.Target), 'g', -1, 64)
+	return c.writer.Write(append([]string{ratio}, result.CSVRecord()...))
+}
+
+// Flush writes the header if nothing has been written yet, so that the output can still be loaded as a table when
+// nothing was found.
+func (c *csvResultWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// writeHeader writes the header row unless it has already been written.
+func (c *csvResultWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+
+	if err := c.writer.Write(append([]string{"ratio"}, csvHeader...)); err != nil {
+		return err
+	}
+
+	c.headerWritten = true
+	return nil
+}
+
+type ndjsonResultWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonResultWriter) Write(result Result) error {
+	return n.encoder.Encode(result)
+}
+
+func (n *ndjsonResultWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// stackPool holds stacks that are no longer in use, so that their buffers can be reused rather than reallocated on
+// every iteration of a search.
+var stackPool = sync.Pool{
+	New: func() interface{} { return &Stack{} },
+}
+
+// getStack returns an empty stack from the pool.
+func getStack() *Stack {
+	return stackPool.Get().(*Stack)
+}
+
+// putStack empties the stack and returns it to the pool. The stack must not be used again afterwards.
+func putStack(s *Stack) {
+	for i := range s.buf {
+		s.buf[i] = nil
+	}
+
+	s.items = s.buf[len(s.buf):]
+	stackPool.Put(s)
+}
+
+// generateInto replaces the contents of the stack with a random expression of the given length, like GenerateInRange,
+// but reusing the stack's buffer where it's large enough.
+func generateInto(r *rand.Rand, s *Stack, length, minNum, maxNum int, opts GenerateOptions) {
+	s.buf = generateRecursive(r, s.buf[:0], minNum, maxNum+1, length, opts)
+	s.items = s.buf
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// BenchmarkSearchIteration generates and evaluates an expression the way a search worker does, with and without
+// reusing stacks from the pool.
+func BenchmarkSearchIteration(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		r := rand.New(rand.NewSource(1))
+		scratch := &Stack{}
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			expression := getStack()
+			generateInto(r, expression, 15, DefaultMinNum, DefaultMaxNum, GenerateOptions{})
+			EvaluateInto(expression, scratch)
+			putStack(expression)
+		}
+	})
+
+	b.Run("unpooled", func(b *testing.B) {
+		r := rand.New(rand.NewSource(1))
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			expression := GenerateInRange(r, 15, DefaultMinNum, DefaultMaxNum, GenerateOptions{})
+			EvaluateInto(expression, &Stack{})
+		}
+	})
+}
+
+func TestPutStackResets(t *testing.T) {
+	s := &Stack{}
+	generateInto(rand.New(rand.NewSource(1)), s, 15, DefaultMinNum, DefaultMaxNum, GenerateOptions{})
+	putStack(s)
+
+	if s.Len() != 0 {
+		t.Errorf("stack still has %d atoms after being returned to the pool", s.Len())
+	}
+
+	for i, atom := range s.buf {
+		if atom != nil {
+			t.Errorf("buffer still holds %v at index %d after being returned to the pool", atom, i)
+		}
+	}
+}
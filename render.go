@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// term is a rendered subexpression along with the precedence of its outermost operator, used to decide where
+// parentheses are needed.
+type term struct {
+	text       string
+	precedence int
+}
+
+// atomPrecedence is the precedence of numbers, constants and function-style operators like √, which never need to be
+// parenthesised.
+const atomPrecedence = 100
+
+// renderer describes how to render each kind of atom for a particular notation.
+type renderer struct {
+	leaf   func(atom Atom) term
+	unary  func(op Operator, x term) term
+	binary func(op Operator, y, x term) term
+}
+
+// render walks the expression in postfix order, combining the rendered operands of each operator.
+func (s *Stack) render(r renderer) (string, error) {
+	terms := []term{}
+
+	for _, atom := range s.items {
+		op, ok := atom.(Operator)
+		if !ok {
+			terms = append(terms, r.leaf(atom))
+			continue
+		}
+
+		if len(terms) < op.Arity() {
+			return "", fmt.Errorf("%w at operator %s", ErrStackUnderflow, op)
+		}
+
+		if op.Arity() == 1 {
+			terms[len(terms)-1] = r.unary(op, terms[len(terms)-1])
+			continue
+		}
+
+		x, y := terms[len(terms)-1], terms[len(terms)-2]
+		terms = append(terms[:len(terms)-2], r.binary(op, y, x))
+	}
+
+	if len(terms) != 1 {
+		return "", fmt.Errorf("expected a single result, got %d operands", len(terms))
+	}
+
+	return terms[0].text, nil
+}
+
+// leafTerm renders a number or constant, using name to render constants.
+func leafTerm(atom Atom, name func(Constant) string) term {
+	switch atom := atom.(type) {
+	case Number:
+		if atom < 0 {
+			// A leading minus sign binds like exponentiation, so "-2 2 ^" must be rendered as "(-2) ^ 2".
+			return term{text: fmt.Sprint(atom), precedence: POW.Precedence()}
+		}
+
+		return term{text: fmt.Sprint(atom), precedence: atomPrecedence}
+	case Constant:
+		return term{text: name(atom), precedence: atomPrecedence}
+	default:
+		return term{text: fmt.Sprint(atom), precedence: atomPrecedence}
+	}
+}
+
+// needsParens reports whether the left (y) and right (x) operands of a binary operator need parentheses.
+func needsParens(op Operator, y, x term) (bool, bool) {
+	precedence := op.Precedence()
+
+	// Exponentiation is right-associative, so it's the left operand that needs parentheses when the precedence is
+	// equal. Subtraction, division and modulo are left-associative and not associative, so the right operand does.
+	left := y.precedence < precedence || (op == POW && y.precedence == precedence)
+	right := x.precedence < precedence || ((op == SUB || op == DIV || op == MOD) && x.precedence == precedence)
+
+	return left, right
+}
+
+// Infix renders the expression in conventional infix notation, adding only the parentheses needed to preserve the
+// order of evaluation. For example, "3 4 + 5 *" is rendered as "(3 + 4) * 5".
+func (s *Stack) Infix() (string, error) {
+	return s.render(renderer{
+		leaf: func(atom Atom) term {
+			return leafTerm(atom, func(c Constant) string { return string(c) })
+		},
+		unary: func(op Operator, x term) term {
+			switch op {
+			case NEG:
+				return negate(x)
+			case FACT:
+				return factorial(x)
+			case RECIP:
+				// The reciprocal is written as a division, so "2 ⁻¹" is "1 / 2".
+				_, right := needsParens(DIV, term{text: "1", precedence: atomPrecedence}, x)
+				return term{text: "1 / " + parenthesise(x.text, right), precedence: DIV.Precedence()}
+			}
+
+			return term{text: fmt.Sprintf("%s(%s)", op, x.text), precedence: atomPrecedence}
+		},
+		binary: func(op Operator, y, x term) term {
+			if op == ROOT {
+				return term{text: fmt.Sprintf("root(%s, %s)", y.text, x.text), precedence: atomPrecedence}
+			}
+
+			left, right := needsParens(op, y, x)
+
+			return term{
+				text:       strings.Join([]string{parenthesise(y.text, left), string(op), parenthesise(x.text, right)}, " "),
+				precedence: op.Precedence(),
+			}
+		},
+	})
+}
+
+// LaTeX renders the expression as LaTeX, using \sqrt for square roots, \frac for division and \cdot for
+// multiplication. For example, "3 4 + 5 *" is rendered as "(3 + 4) \cdot 5".
+func (s *Stack) LaTeX() (string, error) {
+	return s.render(renderer{
+		leaf: func(atom Atom) term {
+			return leafTerm(atom, func(c Constant) string {
+				if c == PI {
+					return `\pi`
+				}
+
+				return string(c)
+			})
+		},
+		unary: func(op Operator, x term) term {
+			switch op {
+			case NEG:
+				return negate(x)
+			case FACT:
+				return factorial(x)
+			case RECIP:
+				return term{text: fmt.Sprintf(`\frac{1}{%s}`, x.text), precedence: atomPrecedence}
+			case LN:
+				return term{text: fmt.Sprintf(`\ln(%s)`, x.text), precedence: atomPrecedence}
+			case EXP:
+				return term{text: fmt.Sprintf("e^{%s}", x.text), precedence: POW.Precedence()}
+			case SIN, COS, TAN:
+				return term{text: fmt.Sprintf(`\%s(%s)`, op, x.text), precedence: atomPrecedence}
+			}
+
+			return term{text: fmt.Sprintf(`\sqrt{%s}`, x.text), precedence: atomPrecedence}
+		},
+		binary: func(op Operator, y, x term) term {
+			switch op {
+			case DIV:
+				// Fractions group both operands themselves, so never need parentheses inside or around them.
+				return term{text: fmt.Sprintf(`\frac{%s}{%s}`, y.text, x.text), precedence: atomPrecedence}
+			case ROOT:
+				return term{text: fmt.Sprintf(`\sqrt[%s]{%s}`, x.text, y.text), precedence: atomPrecedence}
+			case POW:
+				left, _ := needsParens(op, y, x)
+				return term{text: fmt.Sprintf("%s^{%s}", parenthesise(y.text, left), x.text), precedence: op.Precedence()}
+			}
+
+			symbol := string(op)
+			switch op {
+			case MUL:
+				symbol = `\cdot`
+			case MOD:
+				symbol = `\bmod`
+			}
+
+			left, right := needsParens(op, y, x)
+
+			return term{
+				text:       strings.Join([]string{parenthesise(y.text, left), symbol, parenthesise(x.text, right)}, " "),
+				precedence: op.Precedence(),
+			}
+		},
+	})
+}
+
+// negate renders the negation of a term with a leading minus sign. Like a negative number, the result binds like
+// exponentiation, so that "-(2 ^ 2)" and "(-2) ^ 2" are kept distinct.
+func negate(x term) term {
+	return term{text: "-" + parenthesise(x.text, x.precedence != atomPrecedence), precedence: POW.Precedence()}
+}
+
+// factorial renders the factorial of a term with a trailing exclamation mark, which binds tighter than anything else.
+func factorial(x term) term {
+	return term{text: parenthesise(x.text, x.precedence != atomPrecedence) + "!", precedence: atomPrecedence}
+}
+
+// parenthesise wraps the text in parentheses if needed.
+func parenthesise(text string, needed bool) string {
+	if needed {
+		return "(" + text + ")"
+	}
+
+	return text
+}
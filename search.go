@@ -0,0 +1,738 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Result is an expression found by Search, along with its value and distance from the target.
+type Result struct {
+	// Target is the number the expression approximates, which tells results apart when searching for several targets
+	// at once.
+	Target float64
+
+	Diff       float64
+	Value      float64
+	Expression *Stack
+}
+
+// SearchOptions configures a search.
+type SearchOptions struct {
+	// Precision is the number of decimal places an expression must match the target to, or the number of significant
+	// figures if Relative is set.
+	Precision int
+
+	// Relative makes Precision count significant figures rather than decimal places, so that the allowed error scales
+	// with the magnitude of the target. This makes sense for very large or very small targets, such as 0.0001234,
+	// where a fixed number of decimal places is either too strict or meaningless.
+	Relative bool
+
+	// Epsilon is the allowed distance from the target, taking precedence over Precision when it is non-zero. This
+	// allows tolerances that aren't a power of ten, such as 5e-7. If Relative is also set, Epsilon is a fraction of
+	// the target's magnitude instead, so 1e-6 allows an error of one part in a million.
+	Epsilon float64
+
+	// MinLength and MaxLength are the inclusive bounds on the number of atoms in generated expressions, so a fixed
+	// length can be searched by setting both to the same value. Every strategy keeps its results within them, but
+	// improved seeds can be any length.
+	MinLength int
+	MaxLength int
+
+	// MinNum and MaxNum are the inclusive bounds on the whole numbers used in generated expressions. If both are zero,
+	// DefaultMinNum and DefaultMaxNum are used instead.
+	MinNum int
+	MaxNum int
+
+	// Workers is the number of goroutines used to search, defaulting to runtime.NumCPU() if it is zero. Each worker
+	// generates and evaluates its own expressions without sharing any intermediate state, so the count can be scaled
+	// freely. Every worker has its own source of randomness so that they don't contend over the global one.
+	Workers int
+
+	// Limit is the number of results to find before stopping. The search is unlimited if it is zero.
+	Limit int
+
+	// PreferSimpler breaks ties for the best result in favour of the expression with the lowest Complexity.
+	PreferSimpler bool
+
+	// Dedupe prevents expressions that have already been found from being sent again. Expressions are compared by
+	// their canonical form, so reordering the operands of + or * doesn't count as a new expression.
+	Dedupe bool
+
+	// MaxMagnitude is the largest magnitude any intermediate result of an expression can have, defaulting to
+	// DefaultMaxMagnitude if it is zero. Expressions that grow beyond it, such as through repeated multiplication, are
+	// abandoned part way through being evaluated, since they're unlikely to end up anywhere near a typical target. It
+	// should be raised when searching for very large targets, and can be set to math.Inf(1) to disable the check.
+	MaxMagnitude float64
+
+	// MaxOperators limits how many times each operator can appear in an expression, such as map[Operator]int{SQRT: 2}
+	// to only find expressions with at most two square roots. Operators without a limit can appear any number of
+	// times. Generated expressions that break the limits are skipped rather than avoided, so very tight limits slow the
+	// search down.
+	MaxOperators map[Operator]int
+
+	// Seeds are known expressions, such as near misses from an earlier search, for some of the workers to improve by
+	// simulated annealing rather than generating expressions from scratch. Each seed is annealed towards whichever
+	// target it's closest to, and its improvements are reported like any other expression. If there are seeds, half
+	// of the workers are given over to them, or the only worker if there's just one.
+	Seeds []*Stack
+
+	// AvoidTrivial skips generated expressions containing an operation with an identity operand, such as "x 1 *", so
+	// that results aren't cluttered with needlessly long expressions. See HasTrivialOp.
+	AvoidTrivial bool
+
+	// Strategy is how the workers come up with expressions to try, defaulting to StrategyRandom. Seeds are annealed
+	// whichever strategy is used.
+	Strategy Strategy
+
+	// MaxPerProfile limits how many results can share the same Profile for each target, so that results aren't
+	// flooded with the same expression with slightly different numbers. Results are unlimited if it is zero.
+	MaxPerProfile int
+
+	// GenerateOptions controls which operators and numbers generated expressions can contain. It's read by every
+	// worker, so OperatorWeights mustn't be modified while a search is running.
+	GenerateOptions
+}
+
+// Strategy is a way of searching for expressions.
+type Strategy string
+
+const (
+	// StrategyRandom generates random expressions between MinLength and MaxLength atoms long.
+	StrategyRandom Strategy = "random"
+
+	// StrategyAnneal generates random expressions like StrategyRandom, but improves each of them with simulated
+	// annealing before moving on to the next, reporting it if it got any closer to a target.
+	StrategyAnneal Strategy = "anneal"
+
+	// StrategyGA evolves a population of expressions with EvolveSearch, with each worker evolving towards one of the
+	// targets. Offspring longer than MaxLength are bred from but never reported, and the expressions it evaluates
+	// aren't counted in the search's stats.
+	StrategyGA Strategy = "ga"
+
+	// StrategyExhaustive tries every expression of each length from MinLength to MaxLength in turn, using
+	// EnumerateExpressions, and stops once they've all been tried. It's only practical for short expressions. If the
+	// seeds would take every worker, an extra one is started for the enumeration.
+	StrategyExhaustive Strategy = "exhaustive"
+)
+
+// Strategies lists every search strategy along with a description of it, in the order they should be shown.
+var Strategies = []struct {
+	Strategy    Strategy
+	Description string
+}{
+	{StrategyRandom, "generate random expressions (the default)"},
+	{StrategyAnneal, "improve random expressions with simulated annealing"},
+	{StrategyGA, "evolve expressions with a genetic algorithm"},
+	{StrategyExhaustive, "try every expression between -min-length and -max-length atoms long, then stop"},
+}
+
+// ParseStrategy parses the name of a search strategy, such as "random" or "exhaustive".
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if string(s.Strategy) == name {
+			return s.Strategy, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown strategy %q: expected random, anneal, ga or exhaustive", name)
+}
+
+// DefaultMaxMagnitude is the default value of SearchOptions.MaxMagnitude.
+const DefaultMaxMagnitude = 1e12
+
+// validate returns an error if the options can't be searched with.
+func (opts SearchOptions) validate() error {
+	if opts.MinLength < 1 {
+		return fmt.Errorf("minimum length must be at least 1, got %d", opts.MinLength)
+	}
+
+	if opts.MaxLength < opts.MinLength {
+		return fmt.Errorf("maximum length %d is less than minimum length %d", opts.MaxLength, opts.MinLength)
+	}
+
+	if opts.MaxNum < opts.MinNum {
+		return fmt.Errorf("maximum number %d is less than minimum number %d", opts.MaxNum, opts.MinNum)
+	}
+
+	for op, limit := range opts.MaxOperators {
+		if limit < 0 {
+			return fmt.Errorf("operator limit for %s must not be negative, got %d", op, limit)
+		}
+	}
+
+	for i, seed := range opts.Seeds {
+		if err := seed.Validate(); err != nil {
+			return fmt.Errorf("seed %d is invalid: %w", i+1, err)
+		}
+
+		if val, err := Evaluate(seed); err != nil || !finite(val) {
+			return fmt.Errorf("seed %d can't be evaluated to a finite number", i+1)
+		}
+	}
+
+	if opts.MaxMagnitude < 0 {
+		return fmt.Errorf("maximum magnitude must not be negative, got %g", opts.MaxMagnitude)
+	}
+
+	if opts.Epsilon < 0 {
+		return fmt.Errorf("epsilon must not be negative, got %g", opts.Epsilon)
+	}
+
+	if opts.Strategy != "" {
+		if _, err := ParseStrategy(string(opts.Strategy)); err != nil {
+			return err
+		}
+	}
+
+	if opts.MaxPerProfile < 0 {
+		return fmt.Errorf("maximum results per profile must not be negative, got %d", opts.MaxPerProfile)
+	}
+
+	return nil
+}
+
+// epsilon returns the largest distance from the target that an expression can be and still count as a match.
+func (opts SearchOptions) epsilon(target float64) float64 {
+	if opts.Epsilon != 0 {
+		if opts.Relative && target != 0 && finite(target) {
+			return opts.Epsilon * math.Abs(target)
+		}
+
+		return opts.Epsilon
+	}
+
+	if !opts.Relative || target == 0 || !finite(target) {
+		return math.Pow10(-opts.Precision)
+	}
+
+	// The first significant figure of the target is in the 10^magnitude place, so the last one it must match is
+	// Precision-1 places after that.
+	magnitude := int(math.Floor(math.Log10(math.Abs(target))))
+
+	return math.Pow10(magnitude - opts.Precision + 1)
+}
+
+// Searcher is a handle on a running search.
+type Searcher struct {
+	// evaluated and matches are updated atomically by the workers, and read by Stats. They're first in the struct so
+	// they're 64-bit aligned on 32-bit platforms.
+	evaluated int64
+	matches   int64
+
+	results       chan Result
+	preferSimpler bool
+	start         time.Time
+
+	// best holds the best result for each target, in the order the targets were given.
+	mu   sync.Mutex
+	best []Result
+}
+
+// SearchStats reports the progress of a search.
+type SearchStats struct {
+	// Evaluated is the number of expressions generated and evaluated so far, across every worker.
+	Evaluated int64
+
+	// Matches is the number of results sent on the results channel so far.
+	Matches int64
+
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration
+
+	// Rate is the average number of expressions evaluated per second.
+	Rate float64
+}
+
+// seedIterations is the number of annealing iterations a worker spends on a seed before moving on to the next.
+const seedIterations = 1000
+
+// statsBatch is the number of expressions a worker evaluates between updates to the shared count, to keep the workers
+// from contending over it.
+const statsBatch = 256
+
+// Results returns the channel on which approximations are sent as they are found. It is closed once the search has
+// stopped.
+func (s *Searcher) Results() <-chan Result {
+	return s.results
+}
+
+// Best returns the closest expression to the target seen so far by any worker, even if it wasn't within the required
+// precision. When searching for several targets, it's the closest expression to the first one. The Expression of the
+// result is nil if nothing has been evaluated yet, and otherwise belongs to the caller, so it can be modified without
+// affecting the search.
+func (s *Searcher) Best() Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.best[0].snapshot()
+}
+
+// BestPerTarget is like Best, but returns the closest expression seen so far to each of the targets, in the order they
+// were given.
+func (s *Searcher) BestPerTarget() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := make([]Result, len(s.best))
+	for i, result := range s.best {
+		best[i] = result.snapshot()
+	}
+
+	return best
+}
+
+// snapshot returns a copy of the result with its own copy of the expression, so that it doesn't share a Stack with
+// the search or any other result.
+func (r Result) snapshot() Result {
+	if r.Expression != nil {
+		r.Expression = r.Expression.Clone()
+	}
+
+	return r
+}
+
+// Stats returns the progress of the search so far. It's safe to call while the search is running, such as from a
+// time.Ticker to print a status line.
+func (s *Searcher) Stats() SearchStats {
+	stats := SearchStats{
+		Evaluated: atomic.LoadInt64(&s.evaluated),
+		Matches:   atomic.LoadInt64(&s.matches),
+		Elapsed:   time.Since(s.start),
+	}
+
+	if seconds := stats.Elapsed.Seconds(); seconds > 0 {
+		stats.Rate = float64(stats.Evaluated) / seconds
+	}
+
+	return stats
+}
+
+// offer replaces the best result for the target at index i if the given result is closer to it.
+func (s *Searcher) offer(i int, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := s.best[i]
+
+	switch {
+	case best.Expression == nil || result.Diff < best.Diff:
+		s.best[i] = result
+	case s.preferSimpler && result.Diff == best.Diff && result.Expression.Complexity() < best.Expression.Complexity():
+		s.best[i] = result
+	}
+}
+
+// SearchApprox searches for approximations to the target, returning a channel on which they are sent as they are
+// found. It's a shorthand for Search for callers that don't need the best result.
+func SearchApprox(ctx context.Context, target float64, opts SearchOptions) (<-chan Result, error) {
+	searcher, err := Search(ctx, target, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return searcher.Results(), nil
+}
+
+// Search searches for approximations to the target using basic math operations. Approximations are sent on the
+// searcher's results channel as they are found, and the channel is closed once the context is cancelled or the limit
+// is reached, and every worker has stopped. An error is returned if the options are invalid.
+func Search(ctx context.Context, target float64, opts SearchOptions) (*Searcher, error) {
+	return SearchTargets(ctx, []float64{target}, opts)
+}
+
+// SearchTargets is like Search, but looks for approximations to several targets at once. Generating and evaluating an
+// expression is much more expensive than comparing its value to a target, so this is faster than searching for each
+// target separately. Every result records which target it approximates, and an expression that approximates more than
+// one is sent once for each. The limit applies to the total number of results across all of the targets.
+func SearchTargets(ctx context.Context, targets []float64, opts SearchOptions) (*Searcher, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets to search for")
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.MinNum == 0 && opts.MaxNum == 0 {
+		opts.MinNum, opts.MaxNum = DefaultMinNum, DefaultMaxNum
+	}
+
+	if opts.MaxMagnitude == 0 {
+		opts.MaxMagnitude = DefaultMaxMagnitude
+	}
+
+	epsilons := make([]float64, len(targets))
+	for i, target := range targets {
+		epsilons[i] = opts.epsilon(target)
+	}
+
+	searcher := &Searcher{
+		results:       make(chan Result),
+		preferSimpler: opts.PreferSimpler,
+		start:         time.Now(),
+		best:          make([]Result, len(targets)),
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var found int64
+
+	// seenKey identifies an expression found for the target at a particular index.
+	type seenKey struct {
+		target     int
+		expression string
+	}
+
+	var seenMu sync.Mutex
+	seen := make(map[seenKey]struct{})
+
+	// unseen records the expression as seen for the target at index i, returning false if it or an equivalent
+	// expression had already been seen for that target.
+	unseen := func(i int, expression *Stack) bool {
+		key := seenKey{i, expression.Canonical().String()}
+
+		seenMu.Lock()
+		defer seenMu.Unlock()
+
+		if _, ok := seen[key]; ok {
+			return false
+		}
+
+		seen[key] = struct{}{}
+		return true
+	}
+
+	// profiles counts the results sent for the target at each index with each profile.
+	profiles := make(map[seenKey]int)
+
+	// withinProfile records a result for the target at index i, returning false if there have already been
+	// MaxPerProfile results with the same profile for that target.
+	withinProfile := func(i int, expression *Stack) bool {
+		key := seenKey{i, expression.Profile()}
+
+		seenMu.Lock()
+		defer seenMu.Unlock()
+
+		if profiles[key] >= opts.MaxPerProfile {
+			return false
+		}
+
+		profiles[key]++
+		return true
+	}
+
+	// allowed returns false if the expression should be skipped because of AvoidTrivial or MaxOperators.
+	allowed := func(expression *Stack) bool {
+		return !(opts.AvoidTrivial && expression.HasTrivialOp()) && expression.WithinCounts(opts.MaxOperators)
+	}
+
+	// withinLength returns whether the expression keeps to MinLength and MaxLength. Annealing and breeding both change
+	// an expression's length, so the anneal and ga strategies can wander outside the bounds they started within.
+	withinLength := func(expression *Stack) bool {
+		return expression.Len() >= opts.MinLength && expression.Len() <= opts.MaxLength
+	}
+
+	// report compares an evaluated expression to every target, offering it as the best and sending it as a result
+	// wherever it's close enough. Best holds the reporting worker's best distance to each target so far. It returns
+	// whether the expression was kept, in which case it mustn't be returned to the pool, and whether the limit has been
+	// passed so the worker should stop.
+	report := func(expression *Stack, val float64, best []float64) (kept, stop bool) {
+		for i, target := range targets {
+			diff := math.Abs(target - val)
+			result := Result{Target: target, Diff: diff, Value: val, Expression: expression}
+
+			if diff < best[i] || (opts.PreferSimpler && diff == best[i]) {
+				best[i] = diff
+				searcher.offer(i, result)
+				kept = true
+			}
+
+			if diff >= epsilons[i] || (opts.Dedupe && !unseen(i, expression)) {
+				continue
+			}
+
+			if opts.MaxPerProfile > 0 && !withinProfile(i, expression) {
+				continue
+			}
+
+			kept = true
+
+			n := atomic.AddInt64(&found, 1)
+			if opts.Limit > 0 && n > int64(opts.Limit) {
+				return kept, true
+			}
+
+			// The expression can also be the best one so far, or match several targets, so each result sent gets its
+			// own copy for the receiver to do what it likes with.
+			select {
+			case searcher.results <- result.snapshot():
+				atomic.AddInt64(&searcher.matches, 1)
+			case <-ctx.Done():
+			}
+
+			if n == int64(opts.Limit) {
+				cancel()
+			}
+		}
+
+		return kept, false
+	}
+
+	seedWorkers := 0
+	if len(opts.Seeds) > 0 {
+		seedWorkers = workers / 2
+		if seedWorkers == 0 {
+			seedWorkers = 1
+		}
+	}
+
+	// An exhaustive search only finishes once the enumeration has been worked through, so it always needs a worker of
+	// its own.
+	if opts.Strategy == StrategyExhaustive && seedWorkers == workers {
+		workers++
+	}
+
+	// The exhaustive strategy shares one enumeration between all of the workers, which each evaluate a share of it.
+	var enumerated chan *Stack
+	if opts.Strategy == StrategyExhaustive {
+		enumerated = make(chan *Stack)
+
+		go func() {
+			defer close(enumerated)
+
+			for length := opts.MinLength; length <= opts.MaxLength; length++ {
+				for expression := range EnumerateExpressionsContext(ctx, length, opts.MinNum, opts.MaxNum, opts.GenerateOptions) {
+					select {
+					case enumerated <- expression:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	// wg waits for every worker, while enumerating only waits for those working through the exhaustive enumeration,
+	// so that the search can stop once it's finished even if there are seed workers.
+	var wg, enumerating sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		r := rand.New(rand.NewSource(rand.Int63()))
+
+		// Only the best results for each worker are offered to the searcher, to avoid locking on every iteration.
+		best := make([]float64, len(targets))
+		for i := range best {
+			best[i] = math.Inf(1)
+		}
+
+		if i < seedWorkers {
+			go func(first int) {
+				defer wg.Done()
+
+				// Each seed is replaced by its improvements, so that the worker keeps building on them.
+				seeds := make([]*Stack, len(opts.Seeds))
+				copy(seeds, opts.Seeds)
+
+				for j := first; ctx.Err() == nil; j = (j + 1) % len(seeds) {
+					improved, ok := improveSeed(r, seeds[j], targets, opts.GenerateOptions)
+					atomic.AddInt64(&searcher.evaluated, seedIterations)
+
+					// Annealing adds square roots freely, so an improvement can break the limits the seed kept to. It's
+					// dropped rather than built on, so that the seed doesn't drift any further outside them.
+					if !ok || !allowed(improved) {
+						continue
+					}
+
+					seeds[j] = improved
+
+					val, _ := Evaluate(improved)
+					if _, stop := report(improved, val, best); stop {
+						return
+					}
+				}
+			}(i % len(opts.Seeds))
+
+			continue
+		}
+
+		switch opts.Strategy {
+		case StrategyAnneal:
+			go func() {
+				defer wg.Done()
+
+				for ctx.Err() == nil {
+					start := GenerateInRange(r, r.Intn(opts.MaxLength-opts.MinLength+1)+opts.MinLength, opts.MinNum, opts.MaxNum, opts.GenerateOptions)
+
+					improved, ok := improveSeed(r, start, targets, opts.GenerateOptions)
+					atomic.AddInt64(&searcher.evaluated, seedIterations)
+
+					if !ok || !allowed(improved) || !withinLength(improved) {
+						continue
+					}
+
+					val, _ := Evaluate(improved)
+					if _, stop := report(improved, val, best); stop {
+						return
+					}
+				}
+			}()
+
+			continue
+		case StrategyGA:
+			go func(t int) {
+				defer wg.Done()
+
+				// EvolveSearch only sends expressions within a whole number of decimal places, so it's asked for the
+				// closest precision that's no stricter than the real one, and report does the rest of the filtering.
+				evolved := EvolveSearch(ctx, targets[t], GAOptions{
+					Precision:       int(math.Floor(-math.Log10(epsilons[t]))),
+					MinLength:       opts.MinLength,
+					MaxLength:       opts.MaxLength,
+					MinNum:          opts.MinNum,
+					MaxNum:          opts.MaxNum,
+					Rand:            r,
+					GenerateOptions: opts.GenerateOptions,
+				})
+
+				for result := range evolved {
+					if !allowed(result.Expression) || !withinLength(result.Expression) {
+						continue
+					}
+
+					if _, stop := report(result.Expression, result.Value, best); stop {
+						return
+					}
+				}
+			}(i % len(targets))
+
+			continue
+		case StrategyExhaustive:
+			enumerating.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer enumerating.Done()
+
+				scratch := &Stack{}
+
+				evaluated := int64(0)
+				defer func() { atomic.AddInt64(&searcher.evaluated, evaluated) }()
+
+				for expression := range enumerated {
+					evaluated++
+					if evaluated == statsBatch {
+						atomic.AddInt64(&searcher.evaluated, evaluated)
+						evaluated = 0
+					}
+
+					if !allowed(expression) {
+						continue
+					}
+
+					val, err := evaluate(expression, scratch, opts.MaxMagnitude, nil)
+					if err != nil || !finite(val) {
+						continue
+					}
+
+					if _, stop := report(expression, val, best); stop {
+						return
+					}
+				}
+			}()
+
+			continue
+		}
+
+		go func() {
+			defer wg.Done()
+
+			scratch := &Stack{}
+
+			evaluated := int64(0)
+			defer func() { atomic.AddInt64(&searcher.evaluated, evaluated) }()
+
+			for ctx.Err() == nil {
+				evaluated++
+				if evaluated == statsBatch {
+					atomic.AddInt64(&searcher.evaluated, evaluated)
+					evaluated = 0
+				}
+
+				// Most expressions are thrown away straight after being evaluated, so they're taken from a pool and
+				// only left out of it once they're sent as a result or kept as the best.
+				expression := getStack()
+				generateInto(r, expression, r.Intn(opts.MaxLength-opts.MinLength+1)+opts.MinLength, opts.MinNum, opts.MaxNum, opts.GenerateOptions)
+
+				if !allowed(expression) {
+					putStack(expression)
+					continue
+				}
+
+				val, err := evaluate(expression, scratch, opts.MaxMagnitude, nil)
+				if err != nil || !finite(val) {
+					putStack(expression)
+					continue
+				}
+
+				kept, stop := report(expression, val, best)
+				if stop {
+					return
+				}
+
+				if !kept {
+					putStack(expression)
+				}
+			}
+		}()
+	}
+
+	if opts.Strategy == StrategyExhaustive {
+		go func() {
+			enumerating.Wait()
+			cancel()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(searcher.results)
+	}()
+
+	return searcher, nil
+}
+
+// improveSeed anneals the seed towards whichever of the targets it's closest to, returning the result and true if it
+// got any closer. Operators are swapped for ones that opts allows.
+func improveSeed(r *rand.Rand, seed *Stack, targets []float64, opts GenerateOptions) (*Stack, bool) {
+	val, err := Evaluate(seed)
+	if err != nil || !finite(val) {
+		return nil, false
+	}
+
+	closest := targets[0]
+	for _, target := range targets[1:] {
+		if math.Abs(target-val) < math.Abs(closest-val) {
+			closest = target
+		}
+	}
+
+	improved, diff := Anneal(seed, closest, AnnealOptions{Iterations: seedIterations, Rand: r, GenerateOptions: opts})
+	if diff >= math.Abs(closest-val) {
+		return nil, false
+	}
+
+	return improved, true
+}
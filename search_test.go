@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSearchSkipsNonFinite(t *testing.T) {
+	// With an infinite epsilon every finite expression matches, so only the check for NaN and ±Inf values keeps the
+	// square roots of the negative numbers out of the results.
+	for _, strategy := range []Strategy{StrategyRandom, StrategyExhaustive} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		searcher, err := Search(ctx, 0, SearchOptions{
+			Epsilon:      math.Inf(1),
+			MinLength:    1,
+			MaxLength:    4,
+			MinNum:       -3,
+			MaxNum:       3,
+			MaxMagnitude: math.Inf(1),
+			Limit:        1000,
+			Strategy:     strategy,
+		})
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+
+		results := 0
+		for result := range searcher.Results() {
+			results++
+
+			if !finite(result.Value) {
+				t.Errorf("%s search found %s = %v", strategy, result.Expression, result.Value)
+			}
+		}
+
+		cancel()
+
+		if results == 0 {
+			t.Errorf("%s search didn't find anything", strategy)
+		}
+	}
+}
+
+func TestSeedImprovementsKeepToLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	seed, err := Parse("2 3 4 + *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searcher, err := Search(ctx, math.Pi, SearchOptions{
+		Epsilon:      100,
+		MinLength:    1,
+		MaxLength:    5,
+		Workers:      1,
+		MaxOperators: map[Operator]int{SQRT: 0},
+		AvoidTrivial: true,
+		Seeds:        []*Stack{seed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for result := range searcher.Results() {
+		if !result.Expression.WithinCounts(map[Operator]int{SQRT: 0}) || result.Expression.HasTrivialOp() {
+			t.Errorf("search found %s from a seed, which breaks its limits", result.Expression)
+		}
+	}
+}
+
+func TestSearchKeepsToLength(t *testing.T) {
+	// Annealing and breeding both grow expressions, so without checking the results would wander past MaxLength.
+	for _, strategy := range []Strategy{StrategyAnneal, StrategyGA} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		searcher, err := Search(ctx, 0, SearchOptions{
+			Epsilon:      1e6,
+			MinLength:    3,
+			MaxLength:    6,
+			MaxMagnitude: math.Inf(1),
+			Limit:        200,
+			Strategy:     strategy,
+		})
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+
+		for result := range searcher.Results() {
+			if n := result.Expression.Len(); n < 3 || n > 6 {
+				t.Errorf("%s search found %s, which is %d atoms long", strategy, result.Expression, n)
+			}
+		}
+
+		cancel()
+	}
+}
+
+func TestEvolveSearchNumbers(t *testing.T) {
+	// Only the initial population is reported in the first generation, so every number should be within the bounds.
+	evolved := EvolveSearch(context.Background(), 0, GAOptions{
+		Precision:   -6,
+		Generations: 1,
+		MinNum:      50,
+		MaxNum:      60,
+		Rand:        rand.New(rand.NewSource(1)),
+	})
+
+	results := 0
+	for result := range evolved {
+		results++
+
+		for _, atom := range result.Expression.Atoms() {
+			if n, ok := atom.(Number); ok && (n < 50 || n > 60) {
+				t.Errorf("EvolveSearch found %s, which uses %v", result.Expression, n)
+			}
+		}
+	}
+
+	if results == 0 {
+		t.Error("EvolveSearch didn't find anything")
+	}
+}
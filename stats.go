@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Stats summarises the values of a sample of generated expressions. Min, Max, Mean, Median and StdDev only take
+// finite values into account.
+type Stats struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	StdDev float64
+
+	// NaN and Inf count the expressions that evaluated to NaN or ±Inf, and Errors counts those that couldn't be
+	// evaluated at all, such as because of a division by zero.
+	NaN    int
+	Inf    int
+	Errors int
+
+	// Histogram counts the finite values in buckets of width one, keyed by the lower bound of each bucket.
+	Histogram map[float64]int
+}
+
+// Distribution generates count random expressions of the given length and summarises their values.
+func Distribution(length, count int) Stats {
+	stats := Stats{Count: count, Histogram: make(map[float64]int)}
+	values := make([]float64, 0, count)
+
+	for i := 0; i < count; i++ {
+		val, err := Evaluate(Generate(length))
+
+		switch {
+		case err != nil:
+			stats.Errors++
+		case math.IsNaN(val):
+			stats.NaN++
+		case math.IsInf(val, 0):
+			stats.Inf++
+		default:
+			values = append(values, val)
+			stats.Histogram[math.Floor(val)]++
+		}
+	}
+
+	if len(values) == 0 {
+		return stats
+	}
+
+	sort.Float64s(values)
+
+	stats.Min = values[0]
+	stats.Max = values[len(values)-1]
+
+	if len(values)%2 == 1 {
+		stats.Median = values[len(values)/2]
+	} else {
+		stats.Median = (values[len(values)/2-1] + values[len(values)/2]) / 2
+	}
+
+	var sum float64
+	for _, val := range values {
+		sum += val
+	}
+
+	stats.Mean = sum / float64(len(values))
+
+	var squares float64
+	for _, val := range values {
+		squares += (val - stats.Mean) * (val - stats.Mean)
+	}
+
+	stats.StdDev = math.Sqrt(squares / float64(len(values)))
+
+	return stats
+}
@@ -0,0 +1,71 @@
+package main
+
+import "sync"
+
+// SyncStack wraps a Stack with a mutex so that it can be shared and modified by several goroutines at once.
+type SyncStack struct {
+	mu    sync.Mutex
+	stack *Stack
+}
+
+// NewSyncStack returns a SyncStack holding a copy of the stack.
+func NewSyncStack(s *Stack) *SyncStack {
+	return &SyncStack{stack: s.Clone()}
+}
+
+// Push pushes an atom onto the top of the stack.
+func (s *SyncStack) Push(atom Atom) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stack.Push(atom)
+}
+
+// PopOK removes and returns the top of the stack, or false if the stack is empty. There's no panicking Pop, since
+// another goroutine could empty the stack between checking its length and popping.
+func (s *SyncStack) PopOK() (Atom, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stack.PopOK()
+}
+
+// Len returns the number of atoms in the stack.
+func (s *SyncStack) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stack.Len()
+}
+
+// Do calls f with the underlying stack while holding the lock, for anything that needs several operations to happen
+// together. The stack mustn't be used after f returns.
+func (s *SyncStack) Do(f func(s *Stack)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f(s.stack)
+}
+
+// Snapshot returns a copy of the stack as it is now, which can be used freely without holding the lock.
+func (s *SyncStack) Snapshot() *Stack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stack.Clone()
+}
+
+// Replace sets the stack to a copy of the given one.
+func (s *SyncStack) Replace(stack *Stack) {
+	clone := stack.Clone()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stack = clone
+}
+
+// String returns the stack in postfix notation.
+func (s *SyncStack) String() string {
+	return s.Snapshot().String()
+}
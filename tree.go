@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// Node is an expression in tree form. Leaves hold a number or constant, and every other node holds an operator with
+// its operands as children, in the order they are pushed in postfix notation. For example, "3 4 -" is a SUB node with
+// children 3 and 4.
+type Node struct {
+	Atom     Atom
+	Children []*Node
+}
+
+// Tree converts the expression to tree form. It returns an error if the stack isn't a valid expression with a single
+// root.
+func (s *Stack) Tree() (*Node, error) {
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	nodes := []*Node{}
+
+	for _, atom := range s.items {
+		n := valence(atom)
+		children := make([]*Node, n)
+		copy(children, nodes[len(nodes)-n:])
+
+		nodes = append(nodes[:len(nodes)-n], &Node{Atom: atom, Children: children})
+	}
+
+	return nodes[0], nil
+}
+
+// Stack converts the tree back to a postfix stack.
+func (n *Node) Stack() *Stack {
+	return NewStack(n.postfix(nil)...)
+}
+
+// postfix appends the atoms of the tree to items in postfix order.
+func (n *Node) postfix(items []Atom) []Atom {
+	for _, child := range n.Children {
+		items = child.postfix(items)
+	}
+
+	return append(items, n.Atom)
+}
@@ -0,0 +1,47 @@
+package main
+
+// HasTrivialOp returns true if the expression contains an operation that could be removed without changing its value
+// because one of its operands is an identity, such as "5 1 *", "0 x +", "x 1 /" or "x 1 ^". Only literal identities
+// are spotted, so "5 2 2 / *" isn't counted even though 2 2 / is one. Invalid expressions are never trivial.
+func (s *Stack) HasTrivialOp() bool {
+	tree, err := s.Tree()
+	if err != nil {
+		return false
+	}
+
+	return tree.hasTrivialOp()
+}
+
+// hasTrivialOp returns true if the node or any of its descendants is a trivial operation.
+func (n *Node) hasTrivialOp() bool {
+	if len(n.Children) == 2 {
+		x, y := n.Children[0].Atom, n.Children[1].Atom
+
+		switch n.Atom {
+		case ADD:
+			if x == Number(0) || y == Number(0) {
+				return true
+			}
+		case MUL:
+			if x == Number(1) || y == Number(1) {
+				return true
+			}
+		case SUB:
+			if y == Number(0) {
+				return true
+			}
+		case DIV, POW:
+			if y == Number(1) {
+				return true
+			}
+		}
+	}
+
+	for _, child := range n.Children {
+		if child.hasTrivialOp() {
+			return true
+		}
+	}
+
+	return false
+}
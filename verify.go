@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+)
+
+// verify implements the verify subcommand, which checks how closely a single expression approximates a target. It
+// exits with a non-zero status if the expression can't be parsed, isn't valid or can't be evaluated.
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	target := flags.String("target", "pi", "number the expression approximates, as a decimal or the name of a constant")
+	infix := flags.Bool("infix", false, "parse the expression in infix rather than postfix notation")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pi-search verify [flags] expression")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	approximate, err := parseTarget(*target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	parse := Parse
+	if *infix {
+		parse = ParseInfix
+	}
+
+	expression, err := parse(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := expression.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid expression: %v\n", err)
+		os.Exit(1)
+	}
+
+	val, err := Evaluate(expression)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("expression: %s\n", expression)
+
+	if infix, err := expression.Infix(); err == nil {
+		fmt.Printf("infix: %s\n", infix)
+	}
+
+	fmt.Printf("value: %v\n", val)
+	fmt.Printf("diff: %v\n", math.Abs(approximate-val))
+	fmt.Printf("matching decimals: %d\n", MatchingDecimals(val, approximate))
+	fmt.Printf("matching digits: %d\n", matchedDigits(approximate, val))
+}